@@ -5,9 +5,14 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/util/homedir"
+	"os"
 	"path/filepath"
+	"time"
+	"troubleshooter/pkg"
+	"troubleshooter/pkg/pod"
 )
 
 // podCmd represents the pod command
@@ -17,20 +22,76 @@ var podCmd = &cobra.Command{
 	Long: `Troubleshoot problems related to pod.
 
 Examples:
-# Troubleshoot pod schedule
+# Troubleshoot pod schedule on a specified node
 troubleshoot pod schedule -p xxxx -n yyyy
 
+# Troubleshoot pod schedule on every node in the cluster
+troubleshoot pod schedule -p xxxx
+
+# Rank the nodes a pod could be scheduled to
+troubleshoot pod score -p xxxx
+
+# Generate the tolerations a pod needs to run on a node pool
+troubleshoot pod tolerations -p xxxx --pool node-pool=gpu
+
 # Troubleshoot pod schedule with specified kubeconfig
-troubleshoot pod --kube-config /path/to/kubeconfig schedule -p xxxx -n yyyy`,
+troubleshoot pod --kube-config /path/to/kubeconfig schedule -p xxxx -n yyyy
+
+# Troubleshoot pod schedule offline from the manifests dumped by kubectl cluster-info dump
+troubleshoot pod --from-dir /path/to/dump schedule -p xxxx
+
+# Troubleshoot pod schedule with the cluster's scheduler config and profile
+troubleshoot pod --scheduler-config /path/to/scheduler-config.yaml --profile my-scheduler schedule -p xxxx
+
+Exit codes:
+0 the pod is schedulable or already scheduled
+1 the pod is unschedulable
+2 invalid flags, kubeconfig or scheduler config, or pod, node or profile not found
+3 error talking to the API server or running the plugins
+4 timed out waiting for the API server
+5 the watched pod was deleted or terminated before it was bound`,
 }
 
-var kubeConfigPath string
+var (
+	kubeConfigPath      string
+	schedulerConfigPath string
+	profileName         string
+	syncTimeout         time.Duration
+	fromFiles           []string
+	fromDirs            []string
+)
 
 func init() {
 	rootCmd.AddCommand(podCmd)
-	podCmd.PersistentFlags().StringVar(&kubeConfigPath, "kube-config", defaultKubeConfigPath(), "kubeconfig to access k8s")
+	addClusterFlags(podCmd)
+}
+
+// addClusterFlags adds the flags of the cluster and the scheduler to troubleshoot against.
+func addClusterFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&kubeConfigPath, "kube-config", defaultKubeConfigPath(), "kubeconfig to access k8s")
+	cmd.PersistentFlags().StringVar(&schedulerConfigPath, "scheduler-config", "", "KubeSchedulerConfiguration file (v1beta2 or v1beta3) used by kube-scheduler, use the default config if omitted, its extenders are called over HTTP")
+	cmd.PersistentFlags().StringVar(&profileName, "profile", "", "scheduler profile to use, defaults to the profile matching the pod's schedulerName")
+	cmd.PersistentFlags().StringSliceVar(&fromFiles, "from-file", nil, "yaml or json manifests, e.g. from kubectl get -o yaml, to troubleshoot offline instead of accessing the cluster")
+	cmd.PersistentFlags().StringSliceVar(&fromDirs, "from-dir", nil, "directories of yaml or json manifests, e.g. from kubectl cluster-info dump, to troubleshoot offline instead of accessing the cluster")
+	cmd.PersistentFlags().DurationVar(&syncTimeout, "sync-timeout", 30*time.Second, "timeout waiting for the informer caches to sync")
 }
 
 func defaultKubeConfigPath() string {
 	return filepath.Join(homedir.HomeDir(), ".kube", "config")
 }
+
+// noPass reports the error which prevented the troubleshooting.
+func noPass(err error) {
+	fmt.Fprintln(os.Stderr, "[NoPass] "+err.Error())
+	exitCode = pkg.ExitCodeOf(err)
+}
+
+func verdictExitCode(verdict pod.Verdict) pkg.ExitCode {
+	switch verdict {
+	case pod.VerdictUnschedulable:
+		return pkg.ExitUnschedulable
+	case pod.VerdictDeleted:
+		return pkg.ExitDeleted
+	}
+	return pkg.ExitSchedulable
+}
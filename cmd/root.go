@@ -19,6 +19,7 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"troubleshooter/pkg"
 )
 
 
@@ -38,13 +39,17 @@ to quickly create a Cobra application.`,
 	// Run: func(cmd *cobra.Command, args []string) { },
 }
 
+// exitCode is set by the commands from the outcome of the troubleshooting.
+var exitCode = pkg.ExitSchedulable
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(int(pkg.ExitConfigError))
 	}
+	os.Exit(int(exitCode))
 }
 
 func init() {
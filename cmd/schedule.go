@@ -5,8 +5,8 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"fmt"
 	"github.com/spf13/cobra"
+	"time"
 	"troubleshooter/pkg/pod"
 )
 
@@ -17,9 +17,21 @@ var scheduleCmd = &cobra.Command{
 	Long: `Troubleshoot problems related to pod schedule.
 
 Examples:
-# Troubleshoot pod schedule
+# Troubleshoot pod schedule on a specified node
 troubleshoot pod schedule -p xxxx -n yyyy
 
+# Troubleshoot pod schedule on every node in the cluster
+troubleshoot pod schedule -p xxxx
+
+# Check whether the pod of a manifest not applied yet would be scheduled
+troubleshoot pod schedule -f deployment.yaml
+
+# Print the verdict as json for automation
+troubleshoot pod schedule -p xxxx -o json
+
+# Keep re-evaluating as nodes and pods change, until the pod is bound or 10 minutes elapse
+troubleshoot pod schedule -p xxxx --watch --watch-timeout 10m
+
 # Troubleshoot pod schedule with specified kubeconfig
 troubleshoot pod --kube-config /path/to/kubeconfig schedule -p xxxx -n yyyy`,
 	Run: run,
@@ -29,33 +41,50 @@ var (
 	podName      string
 	podNamespace string
 	nodeName     string
+	outputFormat string
+	podManifest  string
+	watch        bool
+	watchTimeout time.Duration
 )
 
 func init() {
 	podCmd.AddCommand(scheduleCmd)
 	scheduleCmd.Flags().StringVarP(&podName, "pod", "p", "", "pod name in k8s")
-	scheduleCmd.Flags().StringVarP(&nodeName, "node", "n", "", "node name in k8s")
+	scheduleCmd.Flags().StringVarP(&nodeName, "node", "n", "", "node name in k8s, evaluate every node in the cluster if omitted")
 	scheduleCmd.Flags().StringVar(&podNamespace, "namespace", "", "namespace of pod in k8s")
-
-	scheduleCmd.MarkFlagRequired("pod")
-	scheduleCmd.MarkFlagRequired("node")
+	scheduleCmd.Flags().StringVarP(&podManifest, "filename", "f", "", "manifest of a Pod, Deployment, ReplicaSet, StatefulSet, Job, DaemonSet or CronJob to evaluate before creating it")
+	scheduleCmd.Flags().StringVarP(&outputFormat, "output", "o", pod.OutputTable, "output format, one of table|json|yaml")
+	scheduleCmd.Flags().BoolVarP(&watch, "watch", "w", false, "keep re-evaluating the pod on the changes of nodes, pods and PVCs, printing the nodes becoming feasible or not")
+	scheduleCmd.Flags().DurationVar(&watchTimeout, "watch-timeout", 30*time.Minute, "stop watching after this long if the pod is not bound")
 }
 
 func run(cmd *cobra.Command, args []string) {
-	defer func() {
-		if r := recover(); r != nil {
-			if err, ok := r.(error); ok {
-				fmt.Println("[NoPass] " + err.Error())
-			}
-		}
-	}()
-
-	ts := pod.NewScheduleTroubleShooter(
+	ts, err := pod.NewScheduleTroubleShooter(
 		kubeConfigPath,
 		podName,
 		podNamespace,
 		nodeName,
+		pod.WithSchedulerConfig(schedulerConfigPath),
+		pod.WithProfile(profileName),
+		pod.WithCacheSyncTimeout(syncTimeout),
+		pod.WithManifests(fromFiles, fromDirs),
+		pod.WithOutputFormat(outputFormat),
+		pod.WithPodManifest(podManifest),
 	)
+	if err != nil {
+		noPass(err)
+		return
+	}
 
-	ts.Execute()
+	var verdict pod.Verdict
+	if watch {
+		verdict, err = ts.ExecuteWatch(watchTimeout)
+	} else {
+		verdict, err = ts.Execute()
+	}
+	if err != nil {
+		noPass(err)
+		return
+	}
+	exitCode = verdictExitCode(verdict)
 }
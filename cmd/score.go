@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"troubleshooter/pkg/pod"
+)
+
+// scoreCmd represents the score command
+var scoreCmd = &cobra.Command{
+	Use:   "score",
+	Short: "Troubleshoot where pod would be scheduled",
+	Long: `Rank the feasible nodes of a pod by the weighted score of every score plugin.
+For a pod already placed, explain why it landed on its node instead of another.
+
+Examples:
+# Rank nodes for a pending pod
+troubleshoot pod score -p xxxx
+
+# Explain the placement of a pod in a specified namespace
+troubleshoot pod score -p xxxx --namespace yyyy
+
+# Print the ranking as json for automation
+troubleshoot pod score -p xxxx -o json`,
+	Run: runScore,
+}
+
+func init() {
+	podCmd.AddCommand(scoreCmd)
+	scoreCmd.Flags().StringVarP(&podName, "pod", "p", "", "pod name in k8s")
+	scoreCmd.Flags().StringVar(&podNamespace, "namespace", "", "namespace of pod in k8s")
+	scoreCmd.Flags().StringVarP(&outputFormat, "output", "o", pod.OutputTable, "output format, one of table|json|yaml")
+
+	scoreCmd.MarkFlagRequired("pod")
+}
+
+func runScore(cmd *cobra.Command, args []string) {
+	ts, err := pod.NewScheduleTroubleShooter(
+		kubeConfigPath,
+		podName,
+		podNamespace,
+		"",
+		pod.WithSchedulerConfig(schedulerConfigPath),
+		pod.WithProfile(profileName),
+		pod.WithCacheSyncTimeout(syncTimeout),
+		pod.WithManifests(fromFiles, fromDirs),
+		pod.WithOutputFormat(outputFormat),
+	)
+	if err != nil {
+		noPass(err)
+		return
+	}
+
+	verdict, err := ts.ExecuteScore()
+	if err != nil {
+		noPass(err)
+		return
+	}
+	exitCode = verdictExitCode(verdict)
+}
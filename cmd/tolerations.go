@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"troubleshooter/pkg/pod"
+)
+
+// tolerationsCmd represents the tolerations command
+var tolerationsCmd = &cobra.Command{
+	Use:   "tolerations",
+	Short: "Troubleshoot the taints a pod doesn't tolerate",
+	Long: `Match every distinct taint of the nodes against the tolerations of a pod, with the number of nodes carrying it,
+and print the smallest list of tolerations to add for the TaintToleration filter to accept every node of a node pool.
+
+Examples:
+# Show the taints of the cluster the pod doesn't tolerate
+troubleshoot pod tolerations -p xxxx
+
+# Generate the tolerations a pod of a manifest needs to run on the gpu node pool
+troubleshoot pod tolerations -f deployment.yaml --pool node-pool=gpu`,
+	Run: runTolerations,
+}
+
+var nodePool string
+
+func init() {
+	podCmd.AddCommand(tolerationsCmd)
+	tolerationsCmd.Flags().StringVarP(&podName, "pod", "p", "", "pod name in k8s")
+	tolerationsCmd.Flags().StringVar(&podNamespace, "namespace", "", "namespace of pod in k8s")
+	tolerationsCmd.Flags().StringVarP(&podManifest, "filename", "f", "", "manifest of a Pod, Deployment, ReplicaSet, StatefulSet, Job, DaemonSet or CronJob to evaluate before creating it")
+	tolerationsCmd.Flags().StringVar(&nodePool, "pool", "", "label selector of the node pool to generate the tolerations for, every node if omitted")
+	tolerationsCmd.Flags().StringVarP(&outputFormat, "output", "o", pod.OutputTable, "output format, one of table|json|yaml")
+}
+
+func runTolerations(cmd *cobra.Command, args []string) {
+	ts, err := pod.NewScheduleTroubleShooter(
+		kubeConfigPath,
+		podName,
+		podNamespace,
+		"",
+		pod.WithSchedulerConfig(schedulerConfigPath),
+		pod.WithProfile(profileName),
+		pod.WithCacheSyncTimeout(syncTimeout),
+		pod.WithManifests(fromFiles, fromDirs),
+		pod.WithOutputFormat(outputFormat),
+		pod.WithPodManifest(podManifest),
+	)
+	if err != nil {
+		noPass(err)
+		return
+	}
+
+	verdict, err := ts.ExecuteTolerations(nodePool)
+	if err != nil {
+		noPass(err)
+		return
+	}
+	exitCode = verdictExitCode(verdict)
+}
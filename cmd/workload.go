@@ -0,0 +1,117 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"troubleshooter/pkg/pod"
+)
+
+// workloadCmd represents the workload command
+var workloadCmd = &cobra.Command{
+	Use:   "workload",
+	Short: "Troubleshoot problems related to workload",
+	Long: `Troubleshoot problems related to the pods of a workload.
+
+Examples:
+# Troubleshoot the schedule of the pending pods of a deployment
+troubleshoot workload schedule deployment/foo
+
+# Troubleshoot the schedule of the pending pods of a statefulset in a specified namespace
+troubleshoot workload schedule statefulset/foo --namespace yyyy
+
+# Troubleshoot the nodes a daemonset doesn't run on
+troubleshoot workload coverage daemonset/foo`,
+}
+
+var workloadScheduleCmd = &cobra.Command{
+	Use:   "schedule kind/name",
+	Short: "Troubleshoot why the pods of a workload are pending",
+	Long: `Resolve the pending pods owned by a Deployment, ReplicaSet, StatefulSet, DaemonSet or Job,
+and report how many replicas cannot fit and why, once per revision of the pod template.
+
+Examples:
+# Troubleshoot the schedule of the pending pods of a deployment
+troubleshoot workload schedule deployment/foo
+
+# Print the verdict as json for automation
+troubleshoot workload schedule job/foo --namespace yyyy -o json`,
+	Args: cobra.ExactArgs(1),
+	Run:  runWorkloadSchedule,
+}
+
+var workloadCoverageCmd = &cobra.Command{
+	Use:   "coverage daemonset/name",
+	Short: "Troubleshoot the nodes a DaemonSet doesn't run on",
+	Long: `Evaluate the pod of a DaemonSet against every node, pinned to the node like the DaemonSet controller does,
+and list the nodes it would not run on with the reasons of the failing plugins.
+Nodes not selected by the node selector, node affinity or tolerations of the DaemonSet are listed as NotTargeted.
+
+Examples:
+# Troubleshoot why a daemonset is not ready on every node
+troubleshoot workload coverage daemonset/foo --namespace kube-system`,
+	Args: cobra.ExactArgs(1),
+	Run:  runWorkloadCoverage,
+}
+
+var workloadNamespace string
+
+func init() {
+	rootCmd.AddCommand(workloadCmd)
+	addClusterFlags(workloadCmd)
+
+	workloadCmd.AddCommand(workloadScheduleCmd)
+	workloadScheduleCmd.Flags().StringVar(&workloadNamespace, "namespace", "default", "namespace of workload in k8s")
+	workloadScheduleCmd.Flags().StringVarP(&outputFormat, "output", "o", pod.OutputTable, "output format, one of table|json|yaml")
+
+	workloadCmd.AddCommand(workloadCoverageCmd)
+	workloadCoverageCmd.Flags().StringVar(&workloadNamespace, "namespace", "default", "namespace of workload in k8s")
+	workloadCoverageCmd.Flags().StringVarP(&outputFormat, "output", "o", pod.OutputTable, "output format, one of table|json|yaml")
+}
+
+func runWorkloadSchedule(cmd *cobra.Command, args []string) {
+	ts, err := newWorkloadTroubleShooter(args[0])
+	if err != nil {
+		noPass(err)
+		return
+	}
+
+	verdict, err := ts.ExecuteWorkload()
+	if err != nil {
+		noPass(err)
+		return
+	}
+	exitCode = verdictExitCode(verdict)
+}
+
+func runWorkloadCoverage(cmd *cobra.Command, args []string) {
+	ts, err := newWorkloadTroubleShooter(args[0])
+	if err != nil {
+		noPass(err)
+		return
+	}
+
+	verdict, err := ts.ExecuteDaemonSetCoverage()
+	if err != nil {
+		noPass(err)
+		return
+	}
+	exitCode = verdictExitCode(verdict)
+}
+
+func newWorkloadTroubleShooter(ref string) (*pod.ScheduleTroubleShooter, error) {
+	return pod.NewScheduleTroubleShooter(
+		kubeConfigPath,
+		"",
+		workloadNamespace,
+		"",
+		pod.WithSchedulerConfig(schedulerConfigPath),
+		pod.WithProfile(profileName),
+		pod.WithCacheSyncTimeout(syncTimeout),
+		pod.WithManifests(fromFiles, fromDirs),
+		pod.WithOutputFormat(outputFormat),
+		pod.WithWorkload(ref),
+	)
+}
@@ -5,10 +5,15 @@ go 1.17
 require (
 	github.com/briandowns/spinner v1.18.0
 	github.com/spf13/cobra v1.3.0
-	k8s.io/api v0.23.3
-	k8s.io/apimachinery v0.23.3
-	k8s.io/client-go v0.23.3
-	k8s.io/kubernetes v1.23.0
+	k8s.io/api v0.23.10
+	k8s.io/apimachinery v0.23.10
+	k8s.io/client-go v0.23.10
+	k8s.io/cloud-provider v0.23.10
+	k8s.io/component-helpers v0.23.10
+	k8s.io/kube-scheduler v0.23.10
+	k8s.io/kubernetes v1.23.10
+	sigs.k8s.io/scheduler-plugins v0.23.10
+	sigs.k8s.io/yaml v1.3.0
 )
 
 require (
@@ -18,8 +23,13 @@ require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/coreos/go-semver v0.3.0 // indirect
 	github.com/coreos/go-systemd/v22 v22.3.2 // indirect
+	github.com/cyphar/filepath-securejoin v0.2.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/docker/distribution v2.7.1+incompatible // indirect
+	github.com/dustin/go-humanize v1.0.0 // indirect
+	github.com/evanphx/json-patch v4.12.0+incompatible // indirect
 	github.com/fatih/color v1.13.0 // indirect
+	github.com/francoispqt/gojay v1.2.13 // indirect
 	github.com/go-logr/logr v1.2.0 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
@@ -28,27 +38,35 @@ require (
 	github.com/google/gofuzz v1.1.0 // indirect
 	github.com/google/uuid v1.1.2 // indirect
 	github.com/googleapis/gnostic v0.5.5 // indirect
-	github.com/imdario/mergo v0.3.5 // indirect
+	github.com/imdario/mergo v0.3.12 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/jpillora/backoff v1.0.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/k8stopologyawareschedwg/noderesourcetopology-api v0.0.12 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.2-0.20181231171920-c182affec369 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/mwitkow/go-conntrack v0.0.0-20190716064945-2f068394615f // indirect
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	github.com/opencontainers/runc v1.0.2 // indirect
 	github.com/opencontainers/selinux v1.8.2 // indirect
+	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
+	github.com/paypal/load-watcher v0.2.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/prometheus/client_golang v1.11.0 // indirect
 	github.com/prometheus/client_model v0.2.0 // indirect
 	github.com/prometheus/common v0.28.0 // indirect
 	github.com/prometheus/procfs v0.6.0 // indirect
+	github.com/sirupsen/logrus v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	go.etcd.io/etcd/api/v3 v3.5.1 // indirect
 	go.etcd.io/etcd/client/pkg/v3 v3.5.1 // indirect
 	go.etcd.io/etcd/client/v3 v3.5.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
-	go.uber.org/zap v1.19.0 // indirect
+	go.uber.org/zap v1.19.1 // indirect
 	golang.org/x/net v0.0.0-20211209124913-491a49abca63 // indirect
 	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8 // indirect
 	golang.org/x/sys v0.0.0-20211205182925-97ca703d548d // indirect
@@ -56,6 +74,7 @@ require (
 	golang.org/x/text v0.3.7 // indirect
 	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gonum.org/v1/gonum v0.6.2 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20211208223120-3a66f561d7aa // indirect
 	google.golang.org/grpc v1.42.0 // indirect
@@ -63,71 +82,72 @@ require (
 	gopkg.in/inf.v0 v0.9.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
-	k8s.io/apiserver v0.23.0 // indirect
-	k8s.io/cloud-provider v0.23.0 // indirect
-	k8s.io/component-base v0.23.0 // indirect
-	k8s.io/component-helpers v0.23.0 // indirect
-	k8s.io/csi-translation-lib v0.23.0 // indirect
+	k8s.io/apiserver v0.23.10 // indirect
+	k8s.io/component-base v0.23.10 // indirect
+	k8s.io/csi-translation-lib v0.23.10 // indirect
 	k8s.io/klog/v2 v2.30.0 // indirect
 	k8s.io/kube-openapi v0.0.0-20211115234752-e816edb12b65 // indirect
-	k8s.io/kube-scheduler v0.0.0 // indirect
-	k8s.io/mount-utils v0.23.0 // indirect
+	k8s.io/metrics v0.23.10 // indirect
+	k8s.io/mount-utils v0.23.10 // indirect
 	k8s.io/utils v0.0.0-20211116205334-6203023598ed // indirect
 	sigs.k8s.io/json v0.0.0-20211020170558-c049b76a60c6 // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.2.1 // indirect
-	sigs.k8s.io/yaml v1.2.0 // indirect
 )
 
-replace k8s.io/api => k8s.io/api v0.23.0
+replace k8s.io/api => k8s.io/api v0.23.10
 
-replace k8s.io/apiextensions-apiserver => k8s.io/apiextensions-apiserver v0.23.0
+replace k8s.io/apiextensions-apiserver => k8s.io/apiextensions-apiserver v0.23.10
 
-replace k8s.io/apimachinery => k8s.io/apimachinery v0.23.1-rc.0
+replace k8s.io/apimachinery => k8s.io/apimachinery v0.23.10
 
-replace k8s.io/apiserver => k8s.io/apiserver v0.23.0
+replace k8s.io/apiserver => k8s.io/apiserver v0.23.10
 
-replace k8s.io/cli-runtime => k8s.io/cli-runtime v0.23.0
+replace k8s.io/cli-runtime => k8s.io/cli-runtime v0.23.10
 
-replace k8s.io/client-go => k8s.io/client-go v0.23.0
+replace k8s.io/client-go => k8s.io/client-go v0.23.10
 
-replace k8s.io/cloud-provider => k8s.io/cloud-provider v0.23.0
+replace k8s.io/cloud-provider => k8s.io/cloud-provider v0.23.10
 
-replace k8s.io/cluster-bootstrap => k8s.io/cluster-bootstrap v0.23.0
+replace k8s.io/cluster-bootstrap => k8s.io/cluster-bootstrap v0.23.10
 
-replace k8s.io/code-generator => k8s.io/code-generator v0.23.1-rc.0
+replace k8s.io/code-generator => k8s.io/code-generator v0.23.10
 
-replace k8s.io/component-base => k8s.io/component-base v0.23.0
+replace k8s.io/component-base => k8s.io/component-base v0.23.10
 
-replace k8s.io/component-helpers => k8s.io/component-helpers v0.23.0
+replace k8s.io/component-helpers => k8s.io/component-helpers v0.23.10
 
-replace k8s.io/controller-manager => k8s.io/controller-manager v0.23.0
+replace k8s.io/controller-manager => k8s.io/controller-manager v0.23.10
 
-replace k8s.io/cri-api => k8s.io/cri-api v0.23.1-rc.0
+replace k8s.io/cri-api => k8s.io/cri-api v0.23.10
 
-replace k8s.io/csi-translation-lib => k8s.io/csi-translation-lib v0.23.0
+replace k8s.io/csi-translation-lib => k8s.io/csi-translation-lib v0.23.10
 
-replace k8s.io/kube-aggregator => k8s.io/kube-aggregator v0.23.0
+replace k8s.io/kube-aggregator => k8s.io/kube-aggregator v0.23.10
 
-replace k8s.io/kube-controller-manager => k8s.io/kube-controller-manager v0.23.0
+replace k8s.io/kube-controller-manager => k8s.io/kube-controller-manager v0.23.10
 
-replace k8s.io/kube-proxy => k8s.io/kube-proxy v0.23.0
+replace k8s.io/kube-proxy => k8s.io/kube-proxy v0.23.10
 
-replace k8s.io/kube-scheduler => k8s.io/kube-scheduler v0.23.0
+replace k8s.io/kube-scheduler => k8s.io/kube-scheduler v0.23.10
 
-replace k8s.io/kubectl => k8s.io/kubectl v0.23.0
+replace k8s.io/kubectl => k8s.io/kubectl v0.23.10
 
-replace k8s.io/kubelet => k8s.io/kubelet v0.23.0
+replace k8s.io/kubelet => k8s.io/kubelet v0.23.10
 
-replace k8s.io/legacy-cloud-providers => k8s.io/legacy-cloud-providers v0.23.0
+// sigs.k8s.io/scheduler-plugins v0.23.10 is built against k8s.io/kubernetes v1.23.10,
+// the staging modules above are pinned to the same release.
+replace k8s.io/kubernetes => k8s.io/kubernetes v1.23.10
 
-replace k8s.io/metrics => k8s.io/metrics v0.23.0
+replace k8s.io/legacy-cloud-providers => k8s.io/legacy-cloud-providers v0.23.10
 
-replace k8s.io/mount-utils => k8s.io/mount-utils v0.23.2-rc.0
+replace k8s.io/metrics => k8s.io/metrics v0.23.10
 
-replace k8s.io/pod-security-admission => k8s.io/pod-security-admission v0.23.0
+replace k8s.io/mount-utils => k8s.io/mount-utils v0.23.10
 
-replace k8s.io/sample-apiserver => k8s.io/sample-apiserver v0.23.0
+replace k8s.io/pod-security-admission => k8s.io/pod-security-admission v0.23.10
 
-replace k8s.io/sample-cli-plugin => k8s.io/sample-cli-plugin v0.23.0
+replace k8s.io/sample-apiserver => k8s.io/sample-apiserver v0.23.10
 
-replace k8s.io/sample-controller => k8s.io/sample-controller v0.23.0
+replace k8s.io/sample-cli-plugin => k8s.io/sample-cli-plugin v0.23.10
+
+replace k8s.io/sample-controller => k8s.io/sample-controller v0.23.10
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ExitCode tells scripts the outcome of the troubleshooting without parsing the output.
+type ExitCode int
+
+const (
+	// ExitSchedulable is returned when the pod can be or is already scheduled.
+	ExitSchedulable ExitCode = 0
+	// ExitUnschedulable is returned when the pod fits no node.
+	ExitUnschedulable ExitCode = 1
+	// ExitConfigError is returned for invalid flags, kubeconfig or scheduler
+	// config, or a pod, node or profile not found, which the user must resolve.
+	ExitConfigError ExitCode = 2
+	// ExitAPIError is returned when talking to the API server or running the plugins fails.
+	ExitAPIError ExitCode = 3
+	// ExitTimeout is returned when the API server doesn't answer in time.
+	ExitTimeout ExitCode = 4
+	// ExitDeleted is returned when the watched pod is deleted or terminated before it is bound.
+	ExitDeleted ExitCode = 5
+)
+
+type exitError struct {
+	code ExitCode
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// NewConfigError marks err as caused by the input of the user.
+func NewConfigError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: ExitConfigError, err: err}
+}
+
+// NewTimeoutError marks err as caused by waiting too long for the API server.
+func NewTimeoutError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: ExitTimeout, err: err}
+}
+
+// ExitCodeOf classifies err, errors not marked otherwise are API errors.
+func ExitCodeOf(err error) ExitCode {
+	if err == nil {
+		return ExitSchedulable
+	}
+
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	if errors.Is(err, context.DeadlineExceeded) || apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) {
+		return ExitTimeout
+	}
+	return ExitAPIError
+}
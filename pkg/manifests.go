@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadObjectsFromFiles decodes the objects of yaml or json manifests, like the
+// output of `kubectl get -o yaml`. Lists are flattened into their items.
+func LoadObjectsFromFiles(paths ...string) ([]runtime.Object, error) {
+	objects := make([]runtime.Object, 0)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		objs, err := DecodeObjects(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", path, err)
+		}
+		objects = append(objects, objs...)
+	}
+	return objects, nil
+}
+
+// LoadObjectsFromDirs walks the directories for yaml and json manifests, like
+// the output of `kubectl cluster-info dump --output-directory`.
+func LoadObjectsFromDirs(dirs ...string) ([]runtime.Object, error) {
+	paths := make([]string, 0)
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml", ".json":
+				if !info.IsDir() {
+					paths = append(paths, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return LoadObjectsFromFiles(paths...)
+}
+
+// DecodeObjects decodes every document of a yaml stream or json object. Objects
+// of kinds unknown to client-go, e.g. custom resources, are skipped.
+func DecodeObjects(data []byte) ([]runtime.Object, error) {
+	objects := make([]runtime.Object, 0)
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return objects, nil
+			}
+			return nil, err
+		}
+		raw.Raw = bytes.TrimSpace(raw.Raw)
+		if len(raw.Raw) == 0 || bytes.Equal(raw.Raw, []byte("null")) {
+			continue
+		}
+
+		objs, err := decodeObject(raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, objs...)
+	}
+}
+
+func decodeObject(data []byte) ([]runtime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !meta.IsListType(obj) {
+		return []runtime.Object{obj}, nil
+	}
+
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		// The items of a v1.List are kept raw since their kinds are unknown.
+		if unknown, ok := item.(*runtime.Unknown); ok {
+			objs, err := decodeObject(unknown.Raw)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, objs...)
+			continue
+		}
+		objects = append(objects, item)
+	}
+	return objects, nil
+}
+
+// NewOfflineClientSet serves the objects kube-scheduler reads from a fake clientset,
+// so the troubleshooting runs against dumped manifests without cluster access.
+func NewOfflineClientSet(objects []runtime.Object) (kubernetes.Interface, error) {
+	selected := make([]runtime.Object, 0, len(objects))
+	namespaces := make(map[string]bool)
+	referencedNamespaces := make([]string, 0)
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *v1.Namespace:
+			namespaces[o.Name] = true
+		case *v1.Pod:
+			// Pods are keyed by UID in the scheduler framework.
+			if len(o.UID) == 0 {
+				o.UID = types.UID(o.Namespace + "/" + o.Name)
+			}
+			referencedNamespaces = append(referencedNamespaces, o.Namespace)
+		case *v1.PersistentVolumeClaim:
+			referencedNamespaces = append(referencedNamespaces, o.Namespace)
+		}
+
+		if isSchedulingObject(obj) {
+			selected = append(selected, obj)
+		}
+	}
+
+	// Dumps of a few namespaces don't have the namespaces themselves, which
+	// the InterPodAffinity plugin lists.
+	for _, ns := range referencedNamespaces {
+		if len(ns) == 0 || namespaces[ns] {
+			continue
+		}
+		namespaces[ns] = true
+		selected = append(selected, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+	}
+
+	cs := fake.NewSimpleClientset()
+	for _, obj := range selected {
+		// The same object may be dumped more than once, the first one wins.
+		if err := cs.Tracker().Add(obj); err != nil && !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+func isSchedulingObject(obj runtime.Object) bool {
+	switch obj.(type) {
+	case *v1.Pod, *v1.Node, *v1.Namespace, *v1.PersistentVolumeClaim, *v1.PersistentVolume:
+		return true
+	case *storagev1.StorageClass, *storagev1.CSINode, *storagev1.CSIDriver, *storagev1beta1.CSIStorageCapacity:
+		return true
+	case *schedulingv1.PriorityClass, *policyv1.PodDisruptionBudget, *v1.Service, *v1.ReplicationController, *appsv1.ReplicaSet, *appsv1.StatefulSet:
+		return true
+	case *appsv1.Deployment, *appsv1.DaemonSet, *batchv1.Job:
+		return true
+	case *v1.Event:
+		// The events of kube-scheduler are compared with the simulation.
+		return true
+	}
+	return false
+}
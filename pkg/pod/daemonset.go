@@ -0,0 +1,221 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"github.com/briandowns/spinner"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	daemonutil "k8s.io/kubernetes/pkg/controller/daemon/util"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"troubleshooter/pkg"
+)
+
+type NodeCoverageStatus string
+
+const (
+	// CoverageRunning is a node a pod of the DaemonSet is already assigned to.
+	CoverageRunning NodeCoverageStatus = "Running"
+	// CoverageFit is a node the pod of the DaemonSet would be scheduled to.
+	CoverageFit NodeCoverageStatus = "Fit"
+	// CoverageNoFit is a node the DaemonSet controller creates a pod for, but the scheduler rejects it.
+	CoverageNoFit NodeCoverageStatus = "NoFit"
+	// CoverageNotTargeted is a node the DaemonSet controller creates no pod for, because
+	// of the node selector, the node affinity or an untolerated taint of the node.
+	CoverageNotTargeted NodeCoverageStatus = "NotTargeted"
+)
+
+// DaemonSetCoverageReport tells the nodes a DaemonSet runs or would not run on.
+type DaemonSetCoverageReport struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	DaemonSet  WorkloadReference `json:"daemonSet"`
+	Profile    string            `json:"profile"`
+	Verdict    Verdict           `json:"verdict"`
+	Message    string            `json:"message"`
+	// TargetedNodes is the number of nodes the DaemonSet controller wants a pod on,
+	// CoveredNodes the ones of them a pod is or would be scheduled to.
+	TargetedNodes int            `json:"targetedNodes"`
+	CoveredNodes  int            `json:"coveredNodes"`
+	Nodes         []NodeCoverage `json:"nodes"`
+}
+
+type NodeCoverage struct {
+	Name         string             `json:"name"`
+	Status       NodeCoverageStatus `json:"status"`
+	FailedPlugin string             `json:"failedPlugin,omitempty"`
+	Reasons      []string           `json:"reasons,omitempty"`
+	Plugins      []PluginStatus     `json:"plugins,omitempty"`
+}
+
+// ExecuteDaemonSetCoverage prints the nodes the pod of the DaemonSet would not run on.
+func (s *ScheduleTroubleShooter) ExecuteDaemonSetCoverage() (Verdict, error) {
+	if s.workload == nil || s.workload.kind != pkg.KindDaemonSet {
+		return "", pkg.NewConfigError(fmt.Errorf("Coverage is only supported for DaemonSets\n"))
+	}
+
+	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	sp.Start()
+
+	ctx := context.Background()
+	report, err := s.daemonSetCoverageCore(ctx)
+	sp.Stop()
+	if err != nil {
+		return "", err
+	}
+	err = printReport(os.Stdout, s.outputFormat, report, func() string {
+		return formatDaemonSetCoverageReport(report)
+	})
+	if err != nil {
+		return "", err
+	}
+	return report.Verdict, nil
+}
+
+// daemonSetCoverageCore evaluates the pod of the DaemonSet against every node on
+// its own, pinned to the node like the DaemonSet controller does.
+func (s *ScheduleTroubleShooter) daemonSetCoverageCore(ctx context.Context) (*DaemonSetCoverageReport, error) {
+	ds, ok := s.workload.object.(*appsv1.DaemonSet)
+	if !ok {
+		return nil, fmt.Errorf("Workload %T is not a DaemonSet\n", s.workload.object)
+	}
+	report := &DaemonSetCoverageReport{
+		APIVersion: ScheduleReportAPIVersion,
+		Kind:       "DaemonSetCoverageReport",
+		DaemonSet: WorkloadReference{
+			Kind:      pkg.KindDaemonSet,
+			Namespace: ds.Namespace,
+			Name:      ds.Name,
+		},
+		Profile: s.framework.ProfileName(),
+	}
+
+	for _, nodeInfo := range s.nodeInfos {
+		coverage, err := s.nodeCoverage(ctx, ds, nodeInfo)
+		if err != nil {
+			return nil, err
+		}
+		if coverage.Status != CoverageNotTargeted {
+			report.TargetedNodes++
+		}
+		if coverage.Status == CoverageRunning || coverage.Status == CoverageFit {
+			report.CoveredNodes++
+		}
+		report.Nodes = append(report.Nodes, *coverage)
+	}
+
+	if report.CoveredNodes == report.TargetedNodes {
+		report.Verdict = VerdictSchedulable
+		report.Message = fmt.Sprintf("DaemonSet %s/%s can run on all %d targeted nodes", ds.Namespace, ds.Name, report.TargetedNodes)
+	} else {
+		report.Verdict = VerdictUnschedulable
+		report.Message = fmt.Sprintf("DaemonSet %s/%s can run on %d/%d targeted nodes", ds.Namespace, ds.Name, report.CoveredNodes, report.TargetedNodes)
+	}
+	return report, nil
+}
+
+func (s *ScheduleTroubleShooter) nodeCoverage(ctx context.Context, ds *appsv1.DaemonSet, nodeInfo *framework.NodeInfo) (*NodeCoverage, error) {
+	node := nodeInfo.Node()
+	coverage := &NodeCoverage{Name: node.Name}
+	for _, pi := range nodeInfo.Pods {
+		if metav1.IsControlledBy(pi.Pod, ds) {
+			coverage.Status = CoverageRunning
+			return coverage, nil
+		}
+	}
+	// The DaemonSet controller doesn't create pods for the nodes it doesn't
+	// select, so those nodes don't count as missing a pod and aren't filtered.
+	if !daemonShouldRunOnNode(s.pod, node) {
+		coverage.Status = CoverageNotTargeted
+		return coverage, nil
+	}
+
+	pod := s.pod.DeepCopy()
+	pod.Name = fmt.Sprintf("%s-%s", ds.Name, node.Name)
+	pod.UID = types.UID(pod.Namespace + "/" + pod.Name)
+	pod.Spec.Affinity = daemonutil.ReplaceDaemonSetPodNodeNameNodeAffinity(pod.Spec.Affinity, node.Name)
+
+	state := framework.NewCycleState()
+	preFilterStatus := s.framework.RunPreFilterPlugins(ctx, state, pod)
+	if !preFilterStatus.IsSuccess() {
+		if !preFilterStatus.IsUnschedulable() {
+			return nil, preFilterStatus.AsError()
+		}
+		coverage.Status = CoverageNoFit
+		coverage.FailedPlugin = preFilterStatus.FailedPlugin()
+		coverage.Reasons = preFilterStatus.Reasons()
+	} else {
+		statuses, err := runFilterPluginsWithNominatedPods(ctx, s.framework, state, pod, nodeInfo)
+		if err != nil {
+			return nil, err
+		}
+		if len(statuses) == 0 {
+			_, extenderStatuses, err := runExtenderFilters(s.framework.Extenders(), pod, []*framework.NodeInfo{nodeInfo})
+			if err != nil {
+				return nil, err
+			}
+			statuses = extenderStatuses[node.Name]
+		}
+		if len(statuses) == 0 {
+			coverage.Status = CoverageFit
+		} else {
+			merged := statuses.Merge()
+			if merged.Code() == framework.Error {
+				return nil, merged.AsError()
+			}
+			coverage.Status = CoverageNoFit
+			coverage.FailedPlugin = merged.FailedPlugin()
+			coverage.Reasons = merged.Reasons()
+			coverage.Plugins = newPluginStatuses(statuses)
+		}
+	}
+	return coverage, nil
+}
+
+// daemonShouldRunOnNode mirrors the predicates of the DaemonSet controller.
+func daemonShouldRunOnNode(pod *v1.Pod, node *v1.Node) bool {
+	if len(pod.Spec.NodeName) != 0 && pod.Spec.NodeName != node.Name {
+		return false
+	}
+	// Ignore parsing errors like the DaemonSet controller does.
+	if fits, _ := nodeaffinity.GetRequiredNodeAffinity(pod).Match(node); !fits {
+		return false
+	}
+	_, untolerated := corev1helpers.FindMatchingUntoleratedTaint(node.Spec.Taints, pod.Spec.Tolerations, func(t *v1.Taint) bool {
+		return t.Effect == v1.TaintEffectNoExecute || t.Effect == v1.TaintEffectNoSchedule
+	})
+	return !untolerated
+}
+
+func formatDaemonSetCoverageReport(report *DaemonSetCoverageReport) string {
+	var sb strings.Builder
+	uncovered := make([]NodeCoverage, 0)
+	for _, n := range report.Nodes {
+		if n.Status != CoverageRunning && n.Status != CoverageFit {
+			uncovered = append(uncovered, n)
+		}
+	}
+	if len(uncovered) > 0 {
+		w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tSTATUS\tREASONS")
+		for _, n := range uncovered {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", n.Name, n.Status, strings.Join(n.Reasons, ","))
+		}
+		w.Flush()
+	}
+
+	if report.Verdict == VerdictSchedulable {
+		fmt.Fprintf(&sb, "[Success] %s", report.Message)
+	} else {
+		fmt.Fprintf(&sb, "[Fail] %s", report.Message)
+	}
+	return sb.String()
+}
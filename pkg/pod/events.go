@@ -0,0 +1,247 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	eventReasonFailedScheduling = "FailedScheduling"
+	eventReasonScheduled        = "Scheduled"
+)
+
+// noNodeAvailableRegexp matches the message of framework.FitError, newer releases
+// of kube-scheduler append the outcome of preemption.
+var noNodeAvailableRegexp = regexp.MustCompile(`(?s)^0/(\d+) nodes are available: (.*?)\.(?: preemption: .*)?$`)
+
+// reasonsHistogramSeparator separates the "count reason" entries of the histogram,
+// the reasons may contain commas themselves.
+var reasonsHistogramSeparator = regexp.MustCompile(`, \d+ `)
+
+// SchedulerComparison puts what kube-scheduler reported for the pod side by side
+// with the simulation.
+type SchedulerComparison struct {
+	// Event is the reason of the latest scheduling event, FailedScheduling or Scheduled.
+	Event     string                 `json:"event,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	Count     int32                  `json:"count,omitempty"`
+	LastSeen  *metav1.Time           `json:"lastSeen,omitempty"`
+	Condition *PodScheduledCondition `json:"condition,omitempty"`
+	// NumAllNodes is the number of nodes kube-scheduler evaluated, parsed from the message.
+	NumAllNodes   int                `json:"numAllNodes,omitempty"`
+	Reasons       []ReasonComparison `json:"reasons,omitempty"`
+	Disagreements []string           `json:"disagreements,omitempty"`
+}
+
+type PodScheduledCondition struct {
+	Status  v1.ConditionStatus `json:"status"`
+	Reason  string             `json:"reason,omitempty"`
+	Message string             `json:"message,omitempty"`
+}
+
+// ReasonComparison counts the nodes filtered for the reason by kube-scheduler and by the simulation.
+type ReasonComparison struct {
+	Reason    string `json:"reason"`
+	Scheduler int    `json:"scheduler"`
+	Simulated int    `json:"simulated"`
+}
+
+// compareWithScheduler fetches the latest scheduling event and the PodScheduled
+// condition of the pod, and flags where they disagree with the simulation.
+func (s *ScheduleTroubleShooter) compareWithScheduler(ctx context.Context, report *ScheduleReport) (*SchedulerComparison, error) {
+	comparison := &SchedulerComparison{}
+	for _, c := range s.pod.Status.Conditions {
+		if c.Type == v1.PodScheduled {
+			comparison.Condition = &PodScheduledCondition{Status: c.Status, Reason: c.Reason, Message: c.Message}
+		}
+	}
+
+	event, err := s.latestSchedulingEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	message := ""
+	switch {
+	case event != nil:
+		comparison.Event = event.Reason
+		comparison.Message = event.Message
+		comparison.Count = event.Count
+		if event.Series != nil {
+			comparison.Count = event.Series.Count
+		}
+		lastSeen := eventTime(event)
+		comparison.LastSeen = &lastSeen
+		if event.Reason == eventReasonFailedScheduling {
+			message = event.Message
+		}
+	case comparison.Condition != nil && comparison.Condition.Status == v1.ConditionFalse:
+		// Events expire after an hour, the condition keeps the last message.
+		message = comparison.Condition.Message
+	default:
+		return nil, nil
+	}
+
+	simulated := make(map[string]int)
+	for _, n := range report.Nodes {
+		for _, reason := range n.Reasons {
+			simulated[reason]++
+		}
+	}
+
+	if comparison.Event == eventReasonScheduled {
+		if report.Verdict == VerdictUnschedulable {
+			comparison.Disagreements = append(comparison.Disagreements, "kube-scheduler scheduled the pod, the simulation finds no node")
+		}
+		return comparison, nil
+	}
+
+	numAllNodes, reasons, ok := parseNoNodeAvailable(message)
+	if !ok {
+		return comparison, nil
+	}
+	comparison.NumAllNodes = numAllNodes
+	if numAllNodes != len(report.Nodes) {
+		comparison.Disagreements = append(comparison.Disagreements, fmt.Sprintf("kube-scheduler evaluated %d nodes, the simulation %d", numAllNodes, len(report.Nodes)))
+	}
+	if report.Verdict == VerdictSchedulable {
+		comparison.Disagreements = append(comparison.Disagreements, "kube-scheduler found no node, the simulation finds feasible nodes")
+	}
+
+	for reason := range simulated {
+		if _, ok := reasons[reason]; !ok {
+			reasons[reason] = 0
+		}
+	}
+	for reason, count := range reasons {
+		comparison.Reasons = append(comparison.Reasons, ReasonComparison{Reason: reason, Scheduler: count, Simulated: simulated[reason]})
+		if count != simulated[reason] {
+			comparison.Disagreements = append(comparison.Disagreements, fmt.Sprintf("kube-scheduler reported %q on %d node(s), the simulation on %d", reason, count, simulated[reason]))
+		}
+	}
+	sort.Slice(comparison.Reasons, func(i, j int) bool {
+		return comparison.Reasons[i].Reason < comparison.Reasons[j].Reason
+	})
+	sort.Strings(comparison.Disagreements)
+	return comparison, nil
+}
+
+// latestSchedulingEvent returns the latest FailedScheduling or Scheduled event of the pod.
+func (s *ScheduleTroubleShooter) latestSchedulingEvent(ctx context.Context) (*v1.Event, error) {
+	events, err := s.client.CoreV1().Events(s.pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", s.pod.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *v1.Event
+	for i := range events.Items {
+		e := &events.Items[i]
+		// Field selectors are not honored by every client, e.g. the offline one.
+		if e.InvolvedObject.Kind != "Pod" || e.InvolvedObject.Name != s.pod.Name {
+			continue
+		}
+		if len(e.InvolvedObject.UID) != 0 && e.InvolvedObject.UID != s.pod.UID {
+			continue
+		}
+		if e.Reason != eventReasonFailedScheduling && e.Reason != eventReasonScheduled {
+			continue
+		}
+		if latest == nil || eventTime(latest).Time.Before(eventTime(e).Time) {
+			latest = e
+		}
+	}
+	return latest, nil
+}
+
+// eventTime returns when the event was last seen, events of the events.k8s.io
+// API only have the event time and series.
+func eventTime(e *v1.Event) metav1.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return metav1.Time{Time: e.Series.LastObservedTime.Time}
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp
+	case !e.EventTime.IsZero():
+		return metav1.Time{Time: e.EventTime.Time}
+	}
+	return e.FirstTimestamp
+}
+
+// parseNoNodeAvailable parses the message of framework.FitError into the number of
+// nodes and the number of nodes filtered per reason.
+func parseNoNodeAvailable(message string) (int, map[string]int, bool) {
+	match := noNodeAvailableRegexp.FindStringSubmatch(strings.TrimSpace(message))
+	if match == nil {
+		return 0, nil, false
+	}
+	numAllNodes, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, nil, false
+	}
+
+	histogram := match[2]
+	entries := make([]string, 0)
+	start := 0
+	for _, loc := range reasonsHistogramSeparator.FindAllStringIndex(histogram, -1) {
+		entries = append(entries, histogram[start:loc[0]])
+		// The count of the next entry follows the comma and the space.
+		start = loc[0] + 2
+	}
+	entries = append(entries, histogram[start:])
+
+	reasons := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, " ", 2)
+		if len(parts) != 2 {
+			return 0, nil, false
+		}
+		count, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, nil, false
+		}
+		reasons[parts[1]] += count
+	}
+	return numAllNodes, reasons, true
+}
+
+func formatSchedulerComparison(comparison *SchedulerComparison) string {
+	lines := make([]string, 0)
+	switch {
+	case len(comparison.Event) != 0:
+		seen := fmt.Sprintf("%s ago", time.Since(comparison.LastSeen.Time).Round(time.Second))
+		if comparison.Count > 1 {
+			seen = fmt.Sprintf("x%d, %s", comparison.Count, seen)
+		}
+		lines = append(lines, fmt.Sprintf("[Scheduler] %s (%s): %s", comparison.Event, seen, comparison.Message))
+	case comparison.Condition != nil:
+		lines = append(lines, fmt.Sprintf("[Scheduler] PodScheduled=%s %s: %s", comparison.Condition.Status, comparison.Condition.Reason, comparison.Condition.Message))
+	}
+
+	if len(comparison.Reasons) > 0 {
+		var sb strings.Builder
+		w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "REASON\tSCHEDULER\tSIMULATED")
+		for _, r := range comparison.Reasons {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", r.Reason, r.Scheduler, r.Simulated)
+		}
+		w.Flush()
+		lines = append(lines, strings.TrimSuffix(sb.String(), "\n"))
+	}
+
+	if len(comparison.Disagreements) == 0 {
+		lines = append(lines, "The simulation agrees with kube-scheduler")
+	} else {
+		lines = append(lines, fmt.Sprintf("[Disagree] %s", strings.Join(comparison.Disagreements, "; ")))
+		lines = append(lines, "Disagreements usually mean kube-scheduler runs another profile or extenders, or the cluster changed since it reported")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,61 @@
+package pod
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNoNodeAvailable(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		wantNodes   int
+		wantReasons map[string]int
+		wantOK      bool
+	}{
+		{
+			name:        "one reason",
+			message:     "0/3 nodes are available: 3 Insufficient cpu.",
+			wantNodes:   3,
+			wantReasons: map[string]int{"Insufficient cpu": 3},
+			wantOK:      true,
+		},
+		{
+			name:      "reasons containing commas",
+			message:   "0/5 nodes are available: 1 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate, 2 Insufficient memory, 2 node(s) didn't match Pod's node affinity/selector.",
+			wantNodes: 5,
+			wantReasons: map[string]int{
+				"node(s) had taint {dedicated: gpu}, that the pod didn't tolerate": 1,
+				"Insufficient memory":                               2,
+				"node(s) didn't match Pod's node affinity/selector": 2,
+			},
+			wantOK: true,
+		},
+		{
+			name:        "outcome of preemption appended by newer releases",
+			message:     "0/2 nodes are available: 2 Insufficient cpu. preemption: 0/2 nodes are available: 2 No preemption victims found for incoming pod.",
+			wantNodes:   2,
+			wantReasons: map[string]int{"Insufficient cpu": 2},
+			wantOK:      true,
+		},
+		{
+			name:    "not a FitError",
+			message: "running PreBind plugin \"VolumeBinding\": binding volumes: timed out",
+		},
+		{
+			name:    "entry without a count",
+			message: "0/2 nodes are available: Insufficient cpu.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, reasons, ok := parseNoNodeAvailable(tt.message)
+			if ok != tt.wantOK {
+				t.Fatalf("want ok %v, got %v", tt.wantOK, ok)
+			}
+			if nodes != tt.wantNodes || !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("want %d nodes %v, got %d nodes %v", tt.wantNodes, tt.wantReasons, nodes, reasons)
+			}
+		})
+	}
+}
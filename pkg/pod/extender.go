@@ -0,0 +1,158 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	"k8s.io/kubernetes/pkg/scheduler"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
+)
+
+// buildExtenders creates the HTTP extenders of the scheduler config like kube-scheduler
+// does, the ignorable ones last. The NodeResourcesFit plugin has to ignore the resources
+// the extenders manage instead of the scheduler, so the profile to build the framework
+// with is returned, a copy when its args change.
+func buildExtenders(cfg *config.KubeSchedulerConfiguration, profile *config.KubeSchedulerProfile) ([]framework.Extender, *config.KubeSchedulerProfile, error) {
+	extenders := make([]framework.Extender, 0, len(cfg.Extenders))
+	ignorableExtenders := make([]framework.Extender, 0)
+	ignoredExtendedResources := make([]string, 0)
+	for i := range cfg.Extenders {
+		extender, err := scheduler.NewHTTPExtender(&cfg.Extenders[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if extender.IsIgnorable() {
+			ignorableExtenders = append(ignorableExtenders, extender)
+		} else {
+			extenders = append(extenders, extender)
+		}
+		for _, r := range cfg.Extenders[i].ManagedResources {
+			if r.IgnoredByScheduler {
+				ignoredExtendedResources = append(ignoredExtendedResources, r.Name)
+			}
+		}
+	}
+	extenders = append(extenders, ignorableExtenders...)
+
+	if len(ignoredExtendedResources) == 0 {
+		return extenders, profile, nil
+	}
+	// Don't change the args of the loaded config.
+	profile = profile.DeepCopy()
+	for i := range profile.PluginConfig {
+		if profile.PluginConfig[i].Name != noderesources.FitName {
+			continue
+		}
+		args, ok := profile.PluginConfig[i].Args.(*config.NodeResourcesFitArgs)
+		if !ok {
+			return nil, nil, fmt.Errorf("want args to be of type NodeResourcesFitArgs, got %T", profile.PluginConfig[i].Args)
+		}
+		args.IgnoredResources = ignoredExtendedResources
+		return extenders, profile, nil
+	}
+	return nil, nil, fmt.Errorf("can't find NodeResourcesFitArgs in plugin config")
+}
+
+// runExtenderFilters mirrors findNodesThatPassExtenders of kube-scheduler, calling
+// the extenders one after another with the nodes left by the previous ones, but
+// keeps the status of every extender per node, keyed by the extender name.
+func runExtenderFilters(extenders []framework.Extender, pod *v1.Pod, feasibleNodes []*framework.NodeInfo) ([]*framework.NodeInfo, map[string]framework.PluginToStatus, error) {
+	statuses := make(map[string]framework.PluginToStatus)
+	for _, extender := range extenders {
+		if len(feasibleNodes) == 0 {
+			break
+		}
+		if !extender.IsInterested(pod) {
+			continue
+		}
+
+		nodes := make([]*v1.Node, 0, len(feasibleNodes))
+		for _, ni := range feasibleNodes {
+			nodes = append(nodes, ni.Node())
+		}
+		feasibleList, failedMap, failedAndUnresolvableMap, err := extender.Filter(pod, nodes)
+		if err != nil {
+			if extender.IsIgnorable() {
+				continue
+			}
+			return nil, nil, fmt.Errorf("extender %s: %w", extender.Name(), err)
+		}
+
+		failed := make(map[string]*framework.Status)
+		for nodeName, msg := range failedAndUnresolvableMap {
+			failed[nodeName] = framework.NewStatus(framework.UnschedulableAndUnresolvable, msg)
+		}
+		for nodeName, msg := range failedMap {
+			// failedAndUnresolvableMap takes precedence over failedMap.
+			if _, ok := failed[nodeName]; !ok {
+				failed[nodeName] = framework.NewStatus(framework.Unschedulable, msg)
+			}
+		}
+		for nodeName, status := range failed {
+			status.SetFailedPlugin(extender.Name())
+			if _, ok := statuses[nodeName]; !ok {
+				statuses[nodeName] = make(framework.PluginToStatus)
+			}
+			statuses[nodeName][extender.Name()] = status
+		}
+
+		feasible := make(map[string]bool, len(feasibleList))
+		for _, n := range feasibleList {
+			feasible[n.Name] = true
+		}
+		remaining := make([]*framework.NodeInfo, 0, len(feasibleList))
+		for _, ni := range feasibleNodes {
+			if feasible[ni.Node().Name] {
+				remaining = append(remaining, ni)
+				continue
+			}
+			// Nodes dropped without a reason still fail because of the extender.
+			if _, ok := failed[ni.Node().Name]; !ok {
+				status := framework.NewStatus(framework.Unschedulable, "node(s) were filtered out by the extender")
+				status.SetFailedPlugin(extender.Name())
+				if _, ok := statuses[ni.Node().Name]; !ok {
+					statuses[ni.Node().Name] = make(framework.PluginToStatus)
+				}
+				statuses[ni.Node().Name][extender.Name()] = status
+			}
+		}
+		feasibleNodes = remaining
+	}
+	return feasibleNodes, statuses, nil
+}
+
+// runExtenderPrioritizers mirrors prioritizeNodes of kube-scheduler, the scores are
+// weighted and scaled to the range of the score plugins, keyed by the extender name.
+// Prioritization errors are ignored like kube-scheduler does.
+func runExtenderPrioritizers(extenders []framework.Extender, pod *v1.Pod, nodes []*v1.Node) map[string]map[string]int64 {
+	extenderToNodeScores := make(map[string]map[string]int64)
+	for _, extender := range extenders {
+		if !extender.IsInterested(pod) {
+			continue
+		}
+		prioritizedList, weight, err := extender.Prioritize(pod, nodes)
+		if err != nil {
+			continue
+		}
+		nodeScores := make(map[string]int64, len(*prioritizedList))
+		for _, hp := range *prioritizedList {
+			nodeScores[hp.Host] += hp.Score * weight * (framework.MaxNodeScore / extenderv1.MaxExtenderPriority)
+		}
+		extenderToNodeScores[extender.Name()] = nodeScores
+	}
+	return extenderToNodeScores
+}
+
+// extenderScoreColumns names the extenders with a prioritize verb like the
+// score plugins, to show their scores next to the plugins ones.
+func extenderScoreColumns(cfg *config.KubeSchedulerConfiguration) []config.Plugin {
+	columns := make([]config.Plugin, 0)
+	for _, e := range cfg.Extenders {
+		if len(e.PrioritizeVerb) != 0 && e.Weight > 0 {
+			columns = append(columns, config.Plugin{Name: e.URLPrefix, Weight: int32(e.Weight)})
+		}
+	}
+	return columns
+}
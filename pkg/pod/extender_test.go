@@ -0,0 +1,236 @@
+package pod
+
+import (
+	"encoding/json"
+	v1 "k8s.io/api/core/v1"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	"k8s.io/kubernetes/pkg/scheduler"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// stubExtender serves the filter and prioritize verbs of a scheduler extender.
+type stubExtender struct {
+	// failed and unresolvable are the nodes rejected with a reason, dropped are
+	// the nodes left out of the result without one.
+	failed       map[string]string
+	unresolvable map[string]string
+	dropped      map[string]bool
+	scores       map[string]int64
+	err          string
+}
+
+func (e *stubExtender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var args extenderv1.ExtenderArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/filter":
+		// Like a real extender, only the nodes sent are reported.
+		result := extenderv1.ExtenderFilterResult{
+			Nodes:                      &v1.NodeList{},
+			FailedNodes:                make(extenderv1.FailedNodesMap),
+			FailedAndUnresolvableNodes: make(extenderv1.FailedNodesMap),
+			Error:                      e.err,
+		}
+		for _, n := range args.Nodes.Items {
+			if msg, ok := e.failed[n.Name]; ok {
+				result.FailedNodes[n.Name] = msg
+			} else if msg, ok := e.unresolvable[n.Name]; ok {
+				result.FailedAndUnresolvableNodes[n.Name] = msg
+			} else if !e.dropped[n.Name] {
+				result.Nodes.Items = append(result.Nodes.Items, n)
+			}
+		}
+		json.NewEncoder(w).Encode(&result)
+	case "/prioritize":
+		if len(e.err) != 0 {
+			http.Error(w, e.err, http.StatusInternalServerError)
+			return
+		}
+		result := make(extenderv1.HostPriorityList, 0, len(args.Nodes.Items))
+		for _, n := range args.Nodes.Items {
+			result = append(result, extenderv1.HostPriority{Host: n.Name, Score: e.scores[n.Name]})
+		}
+		json.NewEncoder(w).Encode(&result)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newStubExtender(t *testing.T, stub *stubExtender, weight int64, ignorable bool) framework.Extender {
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	extender, err := scheduler.NewHTTPExtender(&config.Extender{
+		URLPrefix:      server.URL,
+		FilterVerb:     "filter",
+		PrioritizeVerb: "prioritize",
+		Weight:         weight,
+		Ignorable:      ignorable,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extender
+}
+
+func TestRunExtenderFilters(t *testing.T) {
+	pod := newTestPod("p").Obj()
+
+	tests := []struct {
+		name         string
+		stubs        []*stubExtender
+		ignorable    []bool
+		wantFeasible []string
+		// wantRejected maps the rejected nodes to the code of their status per extender index.
+		wantRejected map[string]map[int]framework.Code
+		wantErr      bool
+	}{
+		{
+			name: "failed, unresolvable and dropped nodes",
+			stubs: []*stubExtender{{
+				failed:       map[string]string{"n2": "too busy"},
+				unresolvable: map[string]string{"n3": "wrong rack"},
+				dropped:      map[string]bool{"n4": true},
+			}},
+			ignorable:    []bool{false},
+			wantFeasible: []string{"n1"},
+			wantRejected: map[string]map[int]framework.Code{
+				"n2": {0: framework.Unschedulable},
+				"n3": {0: framework.UnschedulableAndUnresolvable},
+				"n4": {0: framework.Unschedulable},
+			},
+		},
+		{
+			name: "the second extender only sees the nodes left by the first",
+			stubs: []*stubExtender{
+				{failed: map[string]string{"n1": "no"}},
+				{failed: map[string]string{"n1": "no", "n2": "no"}},
+			},
+			ignorable:    []bool{false, false},
+			wantFeasible: []string{"n3", "n4"},
+			wantRejected: map[string]map[int]framework.Code{
+				"n1": {0: framework.Unschedulable},
+				"n2": {1: framework.Unschedulable},
+			},
+		},
+		{
+			name:         "ignorable extender error",
+			stubs:        []*stubExtender{{err: "boom"}},
+			ignorable:    []bool{true},
+			wantFeasible: []string{"n1", "n2", "n3", "n4"},
+			wantRejected: map[string]map[int]framework.Code{},
+		},
+		{
+			name:      "extender error",
+			stubs:     []*stubExtender{{err: "boom"}},
+			ignorable: []bool{false},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extenders := make([]framework.Extender, 0, len(tt.stubs))
+			for i, stub := range tt.stubs {
+				extenders = append(extenders, newStubExtender(t, stub, 1, tt.ignorable[i]))
+			}
+
+			feasible, statuses, err := runExtenderFilters(extenders, pod, newTestNodeInfos("n1", "n2", "n3", "n4"))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, 0, len(feasible))
+			for _, ni := range feasible {
+				names = append(names, ni.Node().Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantFeasible) {
+				t.Errorf("feasible nodes: want %v, got %v", tt.wantFeasible, names)
+			}
+
+			got := make(map[string]map[int]framework.Code)
+			for nodeName, pluginStatuses := range statuses {
+				got[nodeName] = make(map[int]framework.Code)
+				for i, extender := range extenders {
+					if status, ok := pluginStatuses[extender.Name()]; ok {
+						got[nodeName][i] = status.Code()
+						if status.FailedPlugin() != extender.Name() {
+							t.Errorf("node %s: want failed plugin %s, got %s", nodeName, extender.Name(), status.FailedPlugin())
+						}
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantRejected) {
+				t.Errorf("rejected nodes: want %v, got %v", tt.wantRejected, got)
+			}
+		})
+	}
+}
+
+func TestRunExtenderPrioritizers(t *testing.T) {
+	pod := newTestPod("p").Obj()
+	nodes := []*v1.Node{
+		st.MakeNode().Name("n1").Obj(),
+		st.MakeNode().Name("n2").Obj(),
+	}
+
+	scoring := newStubExtender(t, &stubExtender{scores: map[string]int64{"n1": 5, "n2": extenderv1.MaxExtenderPriority}}, 2, false)
+	failing := newStubExtender(t, &stubExtender{err: "boom"}, 3, false)
+
+	got := runExtenderPrioritizers([]framework.Extender{scoring, failing}, pod, nodes)
+	// The scores are weighted and scaled from MaxExtenderPriority to MaxNodeScore,
+	// the failing extender is left out.
+	scale := framework.MaxNodeScore / extenderv1.MaxExtenderPriority
+	want := map[string]map[string]int64{
+		scoring.Name(): {"n1": 5 * 2 * scale, "n2": extenderv1.MaxExtenderPriority * 2 * scale},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestBuildExtendersKeepsProfile(t *testing.T) {
+	fitArgs := &config.NodeResourcesFitArgs{}
+	profile := &config.KubeSchedulerProfile{
+		SchedulerName: "default-scheduler",
+		PluginConfig:  []config.PluginConfig{{Name: noderesources.FitName, Args: fitArgs}},
+	}
+	cfg := &config.KubeSchedulerConfiguration{
+		Extenders: []config.Extender{{
+			URLPrefix:  "http://127.0.0.1:0",
+			FilterVerb: "filter",
+			ManagedResources: []config.ExtenderManagedResource{
+				{Name: "example.com/gpu", IgnoredByScheduler: true},
+				{Name: "example.com/fpga"},
+			},
+		}},
+	}
+
+	_, built, err := buildExtenders(cfg, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fitArgs.IgnoredResources) != 0 {
+		t.Errorf("the args of the loaded profile changed: %v", fitArgs.IgnoredResources)
+	}
+	ignored := built.PluginConfig[0].Args.(*config.NodeResourcesFitArgs).IgnoredResources
+	sort.Strings(ignored)
+	if !reflect.DeepEqual(ignored, []string{"example.com/gpu"}) {
+		t.Errorf("want the ignored resources [example.com/gpu], got %v", ignored)
+	}
+}
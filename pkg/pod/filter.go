@@ -0,0 +1,190 @@
+package pod
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+type filterResult struct {
+	feasibleNodes []*framework.NodeInfo
+	diagnosis     framework.Diagnosis
+	// pluginStatuses keeps the statuses of the failed filter plugins per node.
+	pluginStatuses map[string]framework.PluginToStatus
+	// reservedBy maps the nodes which only fail because of the nominated pods
+	// of equal or higher priority to those pods.
+	reservedBy map[string][]*v1.Pod
+}
+
+// findNodesThatFitPod runs PreFilter and Filter plugins against the given nodes
+// in parallel, then the extenders against the feasible ones, returning the
+// feasible nodes and why the other ones were filtered.
+func findNodesThatFitPod(
+	ctx context.Context,
+	fw framework.Framework,
+	state *framework.CycleState,
+	pod *v1.Pod,
+	nodeInfos []*framework.NodeInfo,
+) (*filterResult, error) {
+	result := &filterResult{
+		diagnosis: framework.Diagnosis{
+			NodeToStatusMap:      make(framework.NodeToStatusMap),
+			UnschedulablePlugins: sets.NewString(),
+		},
+		pluginStatuses: make(map[string]framework.PluginToStatus),
+		reservedBy:     make(map[string][]*v1.Pod),
+	}
+
+	preFilterStatus := fw.RunPreFilterPlugins(ctx, state, pod)
+	if !preFilterStatus.IsSuccess() {
+		if !preFilterStatus.IsUnschedulable() {
+			return nil, preFilterStatus.AsError()
+		}
+		// All nodes will have the same status. Some non trivial refactoring is
+		// needed to avoid this copy.
+		for _, n := range nodeInfos {
+			result.diagnosis.NodeToStatusMap[n.Node().Name] = preFilterStatus
+			result.pluginStatuses[n.Node().Name] = framework.PluginToStatus{preFilterStatus.FailedPlugin(): preFilterStatus}
+		}
+		result.diagnosis.UnschedulablePlugins.Insert(preFilterStatus.FailedPlugin())
+		return result, nil
+	}
+
+	pluginStatuses := make([]framework.PluginToStatus, len(nodeInfos))
+	reservedBy := make([][]*v1.Pod, len(nodeInfos))
+	errs := make([]error, len(nodeInfos))
+	fw.Parallelizer().Until(ctx, len(nodeInfos), func(i int) {
+		pluginStatuses[i], errs[i] = runFilterPluginsWithNominatedPods(ctx, fw, state, pod, nodeInfos[i])
+		if errs[i] != nil || pluginStatuses[i].Merge().IsSuccess() {
+			return
+		}
+		reservedBy[i], errs[i] = nominatedPodsReservingNode(ctx, fw, state, pod, nodeInfos[i])
+	})
+
+	result.feasibleNodes = make([]*framework.NodeInfo, 0, len(nodeInfos))
+	for i, statuses := range pluginStatuses {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		status := statuses.Merge()
+		if status.IsSuccess() {
+			result.feasibleNodes = append(result.feasibleNodes, nodeInfos[i])
+			continue
+		}
+		if status.Code() == framework.Error {
+			return nil, status.AsError()
+		}
+		nodeName := nodeInfos[i].Node().Name
+		result.diagnosis.NodeToStatusMap[nodeName] = status
+		result.diagnosis.UnschedulablePlugins.Insert(status.FailedPlugin())
+		result.pluginStatuses[nodeName] = statuses
+		if len(reservedBy[i]) > 0 {
+			result.reservedBy[nodeName] = reservedBy[i]
+		}
+	}
+
+	feasibleNodes, extenderStatuses, err := runExtenderFilters(fw.Extenders(), pod, result.feasibleNodes)
+	if err != nil {
+		return nil, err
+	}
+	result.feasibleNodes = feasibleNodes
+	for nodeName, statuses := range extenderStatuses {
+		result.diagnosis.NodeToStatusMap[nodeName] = statuses.Merge()
+		result.pluginStatuses[nodeName] = statuses
+		for extender := range statuses {
+			result.diagnosis.UnschedulablePlugins.Insert(extender)
+		}
+	}
+
+	return result, nil
+}
+
+// runFilterPluginsWithNominatedPods mirrors the framework runtime of kube-scheduler,
+// but keeps the status of every filter plugin.
+// We run filters twice in some cases. If the node has greater or equal priority
+// nominated pods, we run them when those pods are added to PreFilter state and nodeInfo.
+// If all filters succeed in this pass, we run them again when these
+// nominated pods are not added. This second pass is necessary because some
+// filters such as inter-pod affinity may not pass without the nominated pods.
+// If there are no nominated pods for the node or if the first run of the
+// filters fail, we don't run the second pass.
+func runFilterPluginsWithNominatedPods(ctx context.Context, fw framework.Framework, state *framework.CycleState, pod *v1.Pod, info *framework.NodeInfo) (framework.PluginToStatus, error) {
+	var statuses framework.PluginToStatus
+	podsAdded := false
+	for i := 0; i < 2; i++ {
+		stateToUse := state
+		nodeInfoToUse := info
+		if i == 0 {
+			var err error
+			podsAdded, stateToUse, nodeInfoToUse, err = addNominatedPods(ctx, fw, pod, state, info)
+			if err != nil {
+				return nil, err
+			}
+		} else if !podsAdded || !statuses.Merge().IsSuccess() {
+			break
+		}
+
+		statuses = fw.RunFilterPlugins(ctx, stateToUse, pod, nodeInfoToUse)
+		status := statuses.Merge()
+		if !status.IsSuccess() && !status.IsUnschedulable() {
+			return statuses, nil
+		}
+	}
+
+	return statuses, nil
+}
+
+// addNominatedPods adds pods with equal or greater priority which are nominated
+// to run on the node. It returns 1) whether any pod was added, 2) augmented cycleState,
+// 3) augmented nodeInfo.
+func addNominatedPods(ctx context.Context, fw framework.Framework, pod *v1.Pod, state *framework.CycleState, nodeInfo *framework.NodeInfo) (bool, *framework.CycleState, *framework.NodeInfo, error) {
+	nominatedPodInfos := higherPriorityNominatedPods(fw, pod, nodeInfo)
+	if len(nominatedPodInfos) == 0 {
+		return false, state, nodeInfo, nil
+	}
+	nodeInfoOut := nodeInfo.Clone()
+	stateOut := state.Clone()
+	for _, pi := range nominatedPodInfos {
+		nodeInfoOut.AddPodInfo(pi)
+		status := fw.RunPreFilterExtensionAddPod(ctx, stateOut, pod, pi, nodeInfoOut)
+		if !status.IsSuccess() {
+			return false, state, nodeInfo, status.AsError()
+		}
+	}
+	return true, stateOut, nodeInfoOut, nil
+}
+
+func higherPriorityNominatedPods(fw framework.Framework, pod *v1.Pod, nodeInfo *framework.NodeInfo) []*framework.PodInfo {
+	if nodeInfo.Node() == nil {
+		return nil
+	}
+	podInfos := make([]*framework.PodInfo, 0)
+	for _, pi := range fw.NominatedPodsForNode(nodeInfo.Node().Name) {
+		if corev1helpers.PodPriority(pi.Pod) >= corev1helpers.PodPriority(pod) && pi.Pod.UID != pod.UID {
+			podInfos = append(podInfos, pi)
+		}
+	}
+	return podInfos
+}
+
+// nominatedPodsReservingNode returns the nominated pods of equal or higher priority
+// when the node only fails because of them, i.e. the filters pass without them.
+func nominatedPodsReservingNode(ctx context.Context, fw framework.Framework, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) ([]*v1.Pod, error) {
+	nominatedPodInfos := higherPriorityNominatedPods(fw, pod, nodeInfo)
+	if len(nominatedPodInfos) == 0 {
+		return nil, nil
+	}
+
+	status := fw.RunFilterPlugins(ctx, state, pod, nodeInfo).Merge()
+	if !status.IsSuccess() {
+		return nil, nil
+	}
+
+	pods := make([]*v1.Pod, 0, len(nominatedPodInfos))
+	for _, pi := range nominatedPodInfos {
+		pods = append(pods, pi.Pod)
+	}
+	return pods, nil
+}
@@ -6,6 +6,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	clientset "k8s.io/client-go/kubernetes"
@@ -14,7 +15,9 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"k8s.io/kubernetes/pkg/scheduler/framework/parallelize"
+	"k8s.io/kubernetes/pkg/scheduler/framework/preemption"
 	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	"reflect"
 )
 
 type frameworkOptions struct {
@@ -23,7 +26,9 @@ type frameworkOptions struct {
 	informerFactory      informers.SharedInformerFactory
 	snapshotSharedLister framework.SharedLister
 	extenders            []framework.Extender
+	outOfTreeRegistry    frameworkruntime.Registry
 	runAllFilters        bool
+	podNominator         framework.PodNominator
 	parallelizer         parallelize.Parallelizer
 }
 
@@ -39,64 +44,251 @@ func NewFramework(
 		opt(&options)
 	}
 
+	if len(options.outOfTreeRegistry) > 0 {
+		registry := make(frameworkruntime.Registry, len(r)+len(options.outOfTreeRegistry))
+		if err := registry.Merge(r); err != nil {
+			return nil, err
+		}
+		if err := registry.Merge(options.outOfTreeRegistry); err != nil {
+			return nil, err
+		}
+		r = registry
+	}
+
 	f := &TroubleShootPodScheduleFilterFramework{
+		scorePluginWeight:     make(map[string]int),
 		runAllFilters:         options.runAllFilters,
 		clientSet:             options.clientSet,
 		kubeConfig:            options.kubeConfig,
 		sharedInformerFactory: options.informerFactory,
 		snapshotSharedLister:  options.snapshotSharedLister,
+		podNominator:          options.podNominator,
+		extenders:             options.extenders,
 		parallelizer:          options.parallelizer,
 	}
 
-	plugins, err := f.findNeededPlugins(r, profile)
+	if profile == nil || profile.Plugins == nil {
+		return f, nil
+	}
+	f.profileName = profile.SchedulerName
+
+	pluginsMap, err := f.findNeededPlugins(r, profile)
 	if err != nil {
 		return nil, err
 	}
 
-	f.filterPlugins = make([]framework.FilterPlugin, 0)
-	f.preFilterPlugins = make([]framework.PreFilterPlugin, 0)
-	for _, pl := range plugins {
-		if preFilterPl, ok := pl.(framework.PreFilterPlugin); ok {
-			f.preFilterPlugins = append(f.preFilterPlugins, preFilterPl)
+	// initialize plugins per individual extension points
+	for _, e := range f.getExtensionPoints(profile.Plugins) {
+		if err := updatePluginList(e.slicePtr, *e.plugins, pluginsMap); err != nil {
+			return nil, err
 		}
-		if filterPl, ok := pl.(framework.FilterPlugin); ok {
-			f.filterPlugins = append(f.filterPlugins, filterPl)
+	}
+
+	// initialize multiPoint plugins to their expanded extension points
+	if len(profile.Plugins.MultiPoint.Enabled) > 0 {
+		if err := f.expandMultiPointPlugins(profile, pluginsMap); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := getScoreWeights(f, pluginsMap, append(profile.Plugins.Score.Enabled, profile.Plugins.MultiPoint.Enabled...)); err != nil {
+		return nil, err
+	}
+
+	// Verifying the score weights again since Plugin.Name() could return a different
+	// value from the one used in the configuration.
+	for _, scorePlugin := range f.scorePlugins {
+		if f.scorePluginWeight[scorePlugin.Name()] == 0 {
+			return nil, fmt.Errorf("score plugin %q is not configured with weight", scorePlugin.Name())
 		}
 	}
 
 	return f, nil
 }
 
+// extensionPoint encapsulates desired and applied set of plugins at a specific extension
+// point, only the extension points the troubleshooter runs are tracked.
+type extensionPoint struct {
+	// the set of plugins to be configured at this extension point.
+	plugins *config.PluginSet
+	// a pointer to the slice storing plugins implementations that will run at this
+	// extension point.
+	slicePtr interface{}
+}
+
+func (f *TroubleShootPodScheduleFilterFramework) getExtensionPoints(plugins *config.Plugins) []extensionPoint {
+	return []extensionPoint{
+		{&plugins.PreFilter, &f.preFilterPlugins},
+		{&plugins.Filter, &f.filterPlugins},
+		{&plugins.PostFilter, &f.postFilterPlugins},
+		{&plugins.PreScore, &f.preScorePlugins},
+		{&plugins.Score, &f.scorePlugins},
+	}
+}
+
+// findNeededPlugins instantiates the plugins enabled by the profile, either
+// through MultiPoint or through any of the extension points.
 func (f *TroubleShootPodScheduleFilterFramework) findNeededPlugins(
 	r frameworkruntime.Registry,
 	profile *config.KubeSchedulerProfile,
-) ([]framework.Plugin, error) {
-	enabledPlugins := make(map[string]config.Plugin)
-	for _, plugin := range profile.Plugins.MultiPoint.Enabled {
-		enabledPlugins[plugin.Name] = plugin
-	}
-
-	pluginConfig := make(map[string]runtime.Object)
+) (map[string]framework.Plugin, error) {
+	pluginConfig := make(map[string]runtime.Object, len(profile.PluginConfig))
 	for _, pc := range profile.PluginConfig {
-		if _, ok := enabledPlugins[pc.Name]; ok {
-			pluginConfig[pc.Name] = pc.Args
+		if _, ok := pluginConfig[pc.Name]; ok {
+			return nil, fmt.Errorf("repeated config for plugin %s", pc.Name)
 		}
+		pluginConfig[pc.Name] = pc.Args
 	}
 
-	plugins := make([]framework.Plugin, 0)
+	pluginsNeeded := sets.NewString()
+	for _, pl := range profile.Plugins.MultiPoint.Enabled {
+		pluginsNeeded.Insert(pl.Name)
+	}
+	for _, e := range f.getExtensionPoints(profile.Plugins) {
+		for _, pl := range e.plugins.Enabled {
+			pluginsNeeded.Insert(pl.Name)
+		}
+	}
 
+	plugins := make(map[string]framework.Plugin)
 	for pluginName, factory := range r {
-		args, _ := pluginConfig[pluginName]
-		pl, err := factory(args, f)
+		if !pluginsNeeded.Has(pluginName) {
+			continue
+		}
+
+		pl, err := factory(pluginConfig[pluginName], f)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("initializing plugin %q: %w", pluginName, err)
 		}
-		plugins = append(plugins, pl)
+		plugins[pluginName] = pl
 	}
 
 	return plugins, nil
 }
 
+// getScoreWeights makes sure that, between MultiPoint-Score plugin weights and individual Score
+// plugin weights there is not an overflow of MaxTotalScore.
+func getScoreWeights(f *TroubleShootPodScheduleFilterFramework, pluginsMap map[string]framework.Plugin, plugins []config.Plugin) error {
+	var totalPriority int64
+	scorePlugins := reflect.ValueOf(&f.scorePlugins).Elem()
+	pluginType := scorePlugins.Type().Elem()
+	for _, e := range plugins {
+		pg := pluginsMap[e.Name]
+		if !reflect.TypeOf(pg).Implements(pluginType) {
+			continue
+		}
+
+		// We append MultiPoint plugins to the list of Score plugins. So if this plugin has already been
+		// encountered, let the individual Score weight take precedence.
+		if _, ok := f.scorePluginWeight[e.Name]; ok {
+			continue
+		}
+		// a weight of zero is not permitted, plugins can be disabled explicitly
+		// when configured.
+		f.scorePluginWeight[e.Name] = int(e.Weight)
+		if f.scorePluginWeight[e.Name] == 0 {
+			f.scorePluginWeight[e.Name] = 1
+		}
+
+		// Checks totalPriority against MaxTotalScore to avoid overflow
+		if int64(f.scorePluginWeight[e.Name])*framework.MaxNodeScore > framework.MaxTotalScore-totalPriority {
+			return fmt.Errorf("total score of Score plugins could overflow")
+		}
+		totalPriority += int64(f.scorePluginWeight[e.Name]) * framework.MaxNodeScore
+	}
+	return nil
+}
+
+func updatePluginList(pluginList interface{}, pluginSet config.PluginSet, pluginsMap map[string]framework.Plugin) error {
+	plugins := reflect.ValueOf(pluginList).Elem()
+	pluginType := plugins.Type().Elem()
+	set := sets.NewString()
+	for _, ep := range pluginSet.Enabled {
+		pg, ok := pluginsMap[ep.Name]
+		if !ok {
+			return fmt.Errorf("%s %q does not exist", pluginType.Name(), ep.Name)
+		}
+
+		if !reflect.TypeOf(pg).Implements(pluginType) {
+			return fmt.Errorf("plugin %q does not extend %s plugin", ep.Name, pluginType.Name())
+		}
+
+		if set.Has(ep.Name) {
+			return fmt.Errorf("plugin %q already registered as %q", ep.Name, pluginType.Name())
+		}
+
+		set.Insert(ep.Name)
+
+		newPlugins := reflect.Append(plugins, reflect.ValueOf(pg))
+		plugins.Set(newPlugins)
+	}
+	return nil
+}
+
+// expandMultiPointPlugins mirrors the upstream framework runtime: plugins enabled
+// through MultiPoint are appended to every extension point they implement, unless
+// the extension point disables them or explicitly re-configures them.
+func (f *TroubleShootPodScheduleFilterFramework) expandMultiPointPlugins(profile *config.KubeSchedulerProfile, pluginsMap map[string]framework.Plugin) error {
+	for _, e := range f.getExtensionPoints(profile.Plugins) {
+		plugins := reflect.ValueOf(e.slicePtr).Elem()
+		pluginType := plugins.Type().Elem()
+		// build enabledSet of plugins already registered via normal extension points
+		// to check double registration
+		enabledSet := sets.NewString()
+		for _, plugin := range e.plugins.Enabled {
+			enabledSet.Insert(plugin.Name)
+		}
+
+		disabledSet := sets.NewString()
+		for _, disabledPlugin := range e.plugins.Disabled {
+			disabledSet.Insert(disabledPlugin.Name)
+		}
+		if disabledSet.Has("*") {
+			continue
+		}
+
+		// track plugins enabled via multipoint separately from those enabled by specific extensions,
+		// so that we can distinguish between double-registration and explicit overrides
+		multiPointEnabled := sets.NewString()
+
+		for _, ep := range profile.Plugins.MultiPoint.Enabled {
+			pg, ok := pluginsMap[ep.Name]
+			if !ok {
+				return fmt.Errorf("%s %q does not exist", pluginType.Name(), ep.Name)
+			}
+
+			// if this plugin doesn't implement the type for the current extension we're trying to expand, skip
+			if !reflect.TypeOf(pg).Implements(pluginType) {
+				continue
+			}
+
+			// a plugin that's enabled via MultiPoint can still be disabled for specific extension points
+			if disabledSet.Has(ep.Name) {
+				continue
+			}
+
+			// if this plugin has already been enabled by the specific extension point,
+			// the user intent is to override the default plugin or make some other explicit setting.
+			// Either way, discard the MultiPoint value for this plugin.
+			if enabledSet.Has(ep.Name) {
+				continue
+			}
+
+			// if this plugin is already registered via MultiPoint, then this is
+			// a double registration and an error in the config.
+			if multiPointEnabled.Has(ep.Name) {
+				return fmt.Errorf("plugin %q already registered as %q", ep.Name, pluginType.Name())
+			}
+
+			multiPointEnabled.Insert(ep.Name)
+
+			newPlugins := reflect.Append(plugins, reflect.ValueOf(pg))
+			plugins.Set(newPlugins)
+		}
+	}
+	return nil
+}
+
 func WithRunAllFilters(runAllFilters bool) Option {
 	return func(o *frameworkOptions) {
 		o.runAllFilters = runAllFilters
@@ -133,6 +325,20 @@ func WithExtenders(extenders []framework.Extender) Option {
 	}
 }
 
+// WithOutOfTreeRegistry merges the factories of plugins not shipped with kube-scheduler,
+// e.g. the ones of scheduler-plugins, into the registry the framework is built from.
+func WithOutOfTreeRegistry(registry frameworkruntime.Registry) Option {
+	return func(o *frameworkOptions) {
+		o.outOfTreeRegistry = registry
+	}
+}
+
+func WithPodNominator(nominator framework.PodNominator) Option {
+	return func(o *frameworkOptions) {
+		o.podNominator = nominator
+	}
+}
+
 func WithParallelizer(parallelizer parallelize.Parallelizer) Option {
 	return func(o *frameworkOptions) {
 		o.parallelizer = parallelizer
@@ -141,20 +347,28 @@ func WithParallelizer(parallelizer parallelize.Parallelizer) Option {
 
 func defaultFrameworkOptions() frameworkOptions {
 	return frameworkOptions{
+		podNominator: NewTroubleShootPodScheduleNominator(),
 		parallelizer: parallelize.NewParallelizer(parallelize.DefaultParallelism),
 	}
 }
 
 type TroubleShootPodScheduleFilterFramework struct {
 	framework.Handle
-	preFilterPlugins []framework.PreFilterPlugin
-	filterPlugins    []framework.FilterPlugin
-
+	preFilterPlugins  []framework.PreFilterPlugin
+	filterPlugins     []framework.FilterPlugin
+	postFilterPlugins []framework.PostFilterPlugin
+	preScorePlugins   []framework.PreScorePlugin
+	scorePlugins      []framework.ScorePlugin
+	scorePluginWeight map[string]int
+
+	profileName           string
 	runAllFilters         bool
 	clientSet             kubernetes.Interface
 	kubeConfig            *rest.Config
 	sharedInformerFactory informers.SharedInformerFactory
 	snapshotSharedLister  framework.SharedLister
+	podNominator          framework.PodNominator
+	extenders             []framework.Extender
 
 	parallelizer parallelize.Parallelizer
 }
@@ -165,8 +379,29 @@ func (f *TroubleShootPodScheduleFilterFramework) QueueSortFunc() framework.LessF
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunPostFilterPlugins(ctx context.Context, state *framework.CycleState, pod *v1.Pod, filteredNodeStatusMap framework.NodeToStatusMap) (*framework.PostFilterResult, *framework.Status) {
-	//TODO implement me
-	panic("implement me")
+	statuses := make(framework.PluginToStatus)
+	for _, pl := range f.postFilterPlugins {
+		r, s := f.runPostFilterPlugin(ctx, pl, state, pod, filteredNodeStatusMap)
+		if s.IsSuccess() {
+			return r, s
+		} else if !s.IsUnschedulable() {
+			// Any status other than Success or Unschedulable is Error.
+			return nil, framework.AsStatus(s.AsError())
+		}
+		statuses[pl.Name()] = s
+	}
+
+	return nil, statuses.Merge()
+}
+
+// runPostFilterPlugin never runs the plugin for real since PostFilter plugins evict
+// pods, only the preemption plugins which can be dry run are simulated.
+func (f *TroubleShootPodScheduleFilterFramework) runPostFilterPlugin(ctx context.Context, pl framework.PostFilterPlugin, state *framework.CycleState, pod *v1.Pod, filteredNodeStatusMap framework.NodeToStatusMap) (*framework.PostFilterResult, *framework.Status) {
+	preemptor, ok := pl.(preemption.Interface)
+	if !ok {
+		return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("PostFilter plugin %s can not be dry run", pl.Name()))
+	}
+	return f.dryRunPreemption(ctx, pl.Name(), preemptor, state, pod, filteredNodeStatusMap)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunPreBindPlugins(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
@@ -205,58 +440,152 @@ func (f *TroubleShootPodScheduleFilterFramework) RunBindPlugins(ctx context.Cont
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) HasFilterPlugins() bool {
-	//TODO implement me
-	panic("implement me")
+	return len(f.filterPlugins) > 0
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) HasPostFilterPlugins() bool {
-	//TODO implement me
-	panic("implement me")
+	return len(f.postFilterPlugins) > 0
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) HasScorePlugins() bool {
-	//TODO implement me
-	panic("implement me")
+	return len(f.scorePlugins) > 0
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) ListPlugins() *config.Plugins {
-	//TODO implement me
-	panic("implement me")
+	m := config.Plugins{}
+
+	for _, e := range f.getExtensionPoints(&m) {
+		plugins := reflect.ValueOf(e.slicePtr).Elem()
+		extName := plugins.Type().Elem().Name()
+		var cfgs []config.Plugin
+		for i := 0; i < plugins.Len(); i++ {
+			name := plugins.Index(i).Interface().(framework.Plugin).Name()
+			p := config.Plugin{Name: name}
+			if extName == "ScorePlugin" {
+				// Weights apply only to score plugins.
+				p.Weight = int32(f.scorePluginWeight[name])
+			}
+			cfgs = append(cfgs, p)
+		}
+		if len(cfgs) > 0 {
+			e.plugins.Enabled = cfgs
+		}
+	}
+	return &m
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) ProfileName() string {
-	//TODO implement me
-	panic("implement me")
+	return f.profileName
 }
 
-func (f *TroubleShootPodScheduleFilterFramework) AddNominatedPod(pod *framework.PodInfo, nodeName string) {
-	//TODO implement me
-	panic("implement me")
+func (f *TroubleShootPodScheduleFilterFramework) AddNominatedPod(pod *framework.PodInfo, nominatingInfo *framework.NominatingInfo) {
+	f.podNominator.AddNominatedPod(pod, nominatingInfo)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) DeleteNominatedPodIfExists(pod *v1.Pod) {
-	//TODO implement me
-	panic("implement me")
+	f.podNominator.DeleteNominatedPodIfExists(pod)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) UpdateNominatedPod(oldPod *v1.Pod, newPodInfo *framework.PodInfo) {
-	//TODO implement me
-	panic("implement me")
+	f.podNominator.UpdateNominatedPod(oldPod, newPodInfo)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) NominatedPodsForNode(nodeName string) []*framework.PodInfo {
-	//TODO implement me
-	panic("implement me")
+	return f.podNominator.NominatedPodsForNode(nodeName)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunPreScorePlugins(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodes []*v1.Node) *framework.Status {
-	//TODO implement me
-	panic("implement me")
+	for _, pl := range f.preScorePlugins {
+		status := f.runPreScorePlugin(ctx, pl, state, pod, nodes)
+		if !status.IsSuccess() {
+			return framework.AsStatus(fmt.Errorf("running PreScore plugin %q: %w", pl.Name(), status.AsError()))
+		}
+	}
+
+	return nil
+}
+
+func (f *TroubleShootPodScheduleFilterFramework) runPreScorePlugin(ctx context.Context, pl framework.PreScorePlugin, state *framework.CycleState, pod *v1.Pod, nodes []*v1.Node) *framework.Status {
+	return pl.PreScore(ctx, state, pod, nodes)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunScorePlugins(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodes []*v1.Node) (framework.PluginToNodeScores, *framework.Status) {
-	//TODO implement me
-	panic("implement me")
+	pluginToNodeScores := make(framework.PluginToNodeScores, len(f.scorePlugins))
+	for _, pl := range f.scorePlugins {
+		pluginToNodeScores[pl.Name()] = make(framework.NodeScoreList, len(nodes))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := parallelize.NewErrorChannel()
+
+	// Run Score method for each node in parallel.
+	f.Parallelizer().Until(ctx, len(nodes), func(index int) {
+		for _, pl := range f.scorePlugins {
+			nodeName := nodes[index].Name
+			s, status := f.runScorePlugin(ctx, pl, state, pod, nodeName)
+			if !status.IsSuccess() {
+				err := fmt.Errorf("plugin %q failed with: %w", pl.Name(), status.AsError())
+				errCh.SendErrorWithCancel(err, cancel)
+				return
+			}
+			pluginToNodeScores[pl.Name()][index] = framework.NodeScore{
+				Name:  nodeName,
+				Score: s,
+			}
+		}
+	})
+	if err := errCh.ReceiveError(); err != nil {
+		return nil, framework.AsStatus(fmt.Errorf("running Score plugins: %w", err))
+	}
+
+	// Run NormalizeScore method for each ScorePlugin in parallel.
+	f.Parallelizer().Until(ctx, len(f.scorePlugins), func(index int) {
+		pl := f.scorePlugins[index]
+		nodeScoreList := pluginToNodeScores[pl.Name()]
+		if pl.ScoreExtensions() == nil {
+			return
+		}
+		status := f.runScoreExtension(ctx, pl, state, pod, nodeScoreList)
+		if !status.IsSuccess() {
+			err := fmt.Errorf("plugin %q failed with: %w", pl.Name(), status.AsError())
+			errCh.SendErrorWithCancel(err, cancel)
+			return
+		}
+	})
+	if err := errCh.ReceiveError(); err != nil {
+		return nil, framework.AsStatus(fmt.Errorf("running Normalize on Score plugins: %w", err))
+	}
+
+	// Apply score weights for each ScorePlugin in parallel.
+	f.Parallelizer().Until(ctx, len(f.scorePlugins), func(index int) {
+		pl := f.scorePlugins[index]
+		// Score plugins' weight has been checked when they are initialized.
+		weight := f.scorePluginWeight[pl.Name()]
+		nodeScoreList := pluginToNodeScores[pl.Name()]
+
+		for i, nodeScore := range nodeScoreList {
+			// return error if score plugin returns invalid score.
+			if nodeScore.Score > framework.MaxNodeScore || nodeScore.Score < framework.MinNodeScore {
+				err := fmt.Errorf("plugin %q returns an invalid score %v, it should in the range of [%v, %v] after normalizing", pl.Name(), nodeScore.Score, framework.MinNodeScore, framework.MaxNodeScore)
+				errCh.SendErrorWithCancel(err, cancel)
+				return
+			}
+			nodeScoreList[i].Score = nodeScore.Score * int64(weight)
+		}
+	})
+	if err := errCh.ReceiveError(); err != nil {
+		return nil, framework.AsStatus(fmt.Errorf("applying score weights on Score plugins: %w", err))
+	}
+
+	return pluginToNodeScores, nil
+}
+
+func (f *TroubleShootPodScheduleFilterFramework) runScorePlugin(ctx context.Context, pl framework.ScorePlugin, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	return pl.Score(ctx, state, pod, nodeName)
+}
+
+func (f *TroubleShootPodScheduleFilterFramework) runScoreExtension(ctx context.Context, pl framework.ScorePlugin, state *framework.CycleState, pod *v1.Pod, nodeScoreList framework.NodeScoreList) *framework.Status {
+	return pl.ScoreExtensions().NormalizeScore(ctx, state, pod, nodeScoreList)
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunFilterPlugins(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) framework.PluginToStatus {
@@ -287,13 +616,31 @@ func (f *TroubleShootPodScheduleFilterFramework) runFilterPlugin(ctx context.Con
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunPreFilterExtensionAddPod(ctx context.Context, state *framework.CycleState, podToSchedule *v1.Pod, podInfoToAdd *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status {
-	//TODO implement me
-	panic("implement me")
+	for _, pl := range f.preFilterPlugins {
+		if pl.PreFilterExtensions() == nil {
+			continue
+		}
+		status := pl.PreFilterExtensions().AddPod(ctx, state, podToSchedule, podInfoToAdd, nodeInfo)
+		if !status.IsSuccess() {
+			return framework.AsStatus(fmt.Errorf("running AddPod on PreFilter plugin %q: %w", pl.Name(), status.AsError()))
+		}
+	}
+
+	return nil
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunPreFilterExtensionRemovePod(ctx context.Context, state *framework.CycleState, podToSchedule *v1.Pod, podInfoToRemove *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status {
-	//TODO implement me
-	panic("implement me")
+	for _, pl := range f.preFilterPlugins {
+		if pl.PreFilterExtensions() == nil {
+			continue
+		}
+		status := pl.PreFilterExtensions().RemovePod(ctx, state, podToSchedule, podInfoToRemove, nodeInfo)
+		if !status.IsSuccess() {
+			return framework.AsStatus(fmt.Errorf("running RemovePod on PreFilter plugin %q: %w", pl.Name(), status.AsError()))
+		}
+	}
+
+	return nil
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) SnapshotSharedLister() framework.SharedLister {
@@ -333,12 +680,15 @@ func (f *TroubleShootPodScheduleFilterFramework) SharedInformerFactory() informe
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) RunFilterPluginsWithNominatedPods(ctx context.Context, state *framework.CycleState, pod *v1.Pod, info *framework.NodeInfo) *framework.Status {
-	//TODO implement me
-	panic("implement me")
+	statuses, err := runFilterPluginsWithNominatedPods(ctx, f, state, pod, info)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
+	return statuses.Merge()
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) Extenders() []framework.Extender {
-	return []framework.Extender{}
+	return f.extenders
 }
 
 func (f *TroubleShootPodScheduleFilterFramework) Parallelizer() parallelize.Parallelizer {
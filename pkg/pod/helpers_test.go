@@ -0,0 +1,29 @@
+package pod
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+)
+
+// newTestPod starts a pod of the default namespace, keyed by a UID derived from
+// its name like the pod of a manifest.
+func newTestPod(name string) *st.PodWrapper {
+	return st.MakePod().Namespace("default").Name(name).UID("default/" + name)
+}
+
+// newTestNodeInfo puts the pods on the node.
+func newTestNodeInfo(node *v1.Node, pods ...*v1.Pod) *framework.NodeInfo {
+	ni := framework.NewNodeInfo(pods...)
+	ni.SetNode(node)
+	return ni
+}
+
+// newTestNodeInfos returns the nodes with the names, without any pod.
+func newTestNodeInfos(names ...string) []*framework.NodeInfo {
+	nodeInfos := make([]*framework.NodeInfo, 0, len(names))
+	for _, name := range names {
+		nodeInfos = append(nodeInfos, newTestNodeInfo(st.MakeNode().Name(name).Obj()))
+	}
+	return nodeInfos
+}
@@ -0,0 +1,398 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	corev1nodeaffinity "k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/nodeaffinity"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// maxNodeAffinityDiffs bounds the nodes the table output diffs, the structured
+	// outputs have them all.
+	maxNodeAffinityDiffs = 3
+	// maxClosestNodes bounds the nodes listed as the closest to match.
+	maxClosestNodes = 3
+)
+
+// NodeAffinityExplanation diffs the node selector and the required node affinity
+// of the pod with the nodes the NodeAffinity plugin rejects.
+type NodeAffinityExplanation struct {
+	Nodes []NodeAffinityDiff `json:"nodes"`
+	// ClosestNodes are the nodes of the cluster failing the fewest requirements.
+	ClosestNodes []ClosestNode `json:"closestNodes,omitempty"`
+}
+
+type NodeAffinityDiff struct {
+	Node   string            `json:"node"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// NodeSelector is spec.nodeSelector, every entry must match.
+	NodeSelector []RequirementResult `json:"nodeSelector,omitempty"`
+	// Required are the required terms of the node affinity, one must match.
+	Required []NodeSelectorTermResult `json:"required,omitempty"`
+	// AddedRequired are the required terms of the addedAffinity of the profile, one must match.
+	AddedRequired []NodeSelectorTermResult `json:"addedRequired,omitempty"`
+	Matched       bool                     `json:"matched"`
+}
+
+type NodeSelectorTermResult struct {
+	Requirements []RequirementResult `json:"requirements,omitempty"`
+	Matched      bool                `json:"matched"`
+}
+
+// RequirementResult is a requirement evaluated against the label of the node, or
+// its field for matchFields.
+type RequirementResult struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+	Field    bool     `json:"field,omitempty"`
+	// NodeValue is the value of the label or field, nil when the node lacks it.
+	NodeValue *string `json:"nodeValue"`
+	Matched   bool    `json:"matched"`
+}
+
+type ClosestNode struct {
+	Name               string `json:"name"`
+	FailedRequirements int    `json:"failedRequirements"`
+}
+
+// explainNodeAffinity diffs the nodes the NodeAffinity plugin rejects, and ranks
+// the nodes of the cluster by how many requirements they miss.
+func (s *ScheduleTroubleShooter) explainNodeAffinity(report *ScheduleReport) (*NodeAffinityExplanation, error) {
+	rejected := make([]string, 0)
+	for _, n := range report.Nodes {
+		if failsPlugin(n, nodeaffinity.Name) {
+			rejected = append(rejected, n.Name)
+		}
+	}
+	if len(rejected) == 0 {
+		return nil, nil
+	}
+
+	added, err := addedNodeAffinity(s.profile)
+	if err != nil {
+		return nil, err
+	}
+	allNodes, err := s.snapshot.NodeInfos().List()
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &NodeAffinityExplanation{}
+	diffs := make(map[string]*NodeAffinityDiff, len(allNodes))
+	for _, n := range allNodes {
+		diffs[n.Node().Name] = diffNodeAffinity(s.pod, added, n.Node())
+		explanation.ClosestNodes = append(explanation.ClosestNodes, ClosestNode{
+			Name:               n.Node().Name,
+			FailedRequirements: failedRequirements(diffs[n.Node().Name]),
+		})
+	}
+	for _, name := range rejected {
+		if diff, ok := diffs[name]; ok {
+			explanation.Nodes = append(explanation.Nodes, *diff)
+		}
+	}
+	sort.SliceStable(explanation.ClosestNodes, func(i, j int) bool {
+		return explanation.ClosestNodes[i].FailedRequirements < explanation.ClosestNodes[j].FailedRequirements
+	})
+	if len(explanation.ClosestNodes) > maxClosestNodes {
+		explanation.ClosestNodes = explanation.ClosestNodes[:maxClosestNodes]
+	}
+	return explanation, nil
+}
+
+// addedNodeAffinity returns the addedAffinity of the NodeAffinity args of the profile.
+func addedNodeAffinity(profile *config.KubeSchedulerProfile) (*v1.NodeAffinity, error) {
+	for _, pc := range profile.PluginConfig {
+		if pc.Name != nodeaffinity.Name || pc.Args == nil {
+			continue
+		}
+		args, ok := pc.Args.(*config.NodeAffinityArgs)
+		if !ok {
+			return nil, fmt.Errorf("want args to be of type NodeAffinityArgs, got %T", pc.Args)
+		}
+		return args.AddedAffinity, nil
+	}
+	return nil, nil
+}
+
+// diffNodeAffinity evaluates every requirement of the pod and the profile on its
+// own against the node.
+func diffNodeAffinity(pod *v1.Pod, added *v1.NodeAffinity, node *v1.Node) *NodeAffinityDiff {
+	diff := &NodeAffinityDiff{Node: node.Name, Labels: node.Labels, Matched: true}
+
+	keys := make([]string, 0, len(pod.Spec.NodeSelector))
+	for key := range pod.Spec.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := RequirementResult{Key: key, Operator: "=", Values: []string{pod.Spec.NodeSelector[key]}}
+		if value, ok := node.Labels[key]; ok {
+			r.NodeValue = &value
+			r.Matched = value == pod.Spec.NodeSelector[key]
+		}
+		diff.Matched = diff.Matched && r.Matched
+		diff.NodeSelector = append(diff.NodeSelector, r)
+	}
+
+	affinity := pod.Spec.Affinity
+	if affinity != nil && affinity.NodeAffinity != nil && affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		var matched bool
+		diff.Required, matched = evaluateNodeSelector(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution, node)
+		diff.Matched = diff.Matched && matched
+	}
+	if added != nil && added.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		var matched bool
+		diff.AddedRequired, matched = evaluateNodeSelector(added.RequiredDuringSchedulingIgnoredDuringExecution, node)
+		diff.Matched = diff.Matched && matched
+	}
+	return diff
+}
+
+// evaluateNodeSelector evaluates the terms, the node matches the selector when it matches one of them.
+func evaluateNodeSelector(selector *v1.NodeSelector, node *v1.Node) ([]NodeSelectorTermResult, bool) {
+	results := make([]NodeSelectorTermResult, 0, len(selector.NodeSelectorTerms))
+	matched := false
+	for _, term := range selector.NodeSelectorTerms {
+		result := evaluateNodeSelectorTerm(term, node)
+		matched = matched || result.Matched
+		results = append(results, result)
+	}
+	return results, matched
+}
+
+// evaluateNodeSelectorTerm evaluates the requirements of the term, an empty term matches no node.
+func evaluateNodeSelectorTerm(term v1.NodeSelectorTerm, node *v1.Node) NodeSelectorTermResult {
+	result := NodeSelectorTermResult{Matched: len(term.MatchExpressions) != 0 || len(term.MatchFields) != 0}
+	for _, r := range term.MatchExpressions {
+		rr := RequirementResult{
+			Key:      r.Key,
+			Operator: string(r.Operator),
+			Values:   r.Values,
+			Matched:  nodeSelectorTermMatches(v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{r}}, node),
+		}
+		if value, ok := node.Labels[r.Key]; ok {
+			rr.NodeValue = &value
+		}
+		result.Matched = result.Matched && rr.Matched
+		result.Requirements = append(result.Requirements, rr)
+	}
+	for _, r := range term.MatchFields {
+		rr := RequirementResult{
+			Key:      r.Key,
+			Operator: string(r.Operator),
+			Values:   r.Values,
+			Field:    true,
+			Matched:  nodeSelectorTermMatches(v1.NodeSelectorTerm{MatchFields: []v1.NodeSelectorRequirement{r}}, node),
+		}
+		if r.Key == "metadata.name" {
+			name := node.Name
+			rr.NodeValue = &name
+		}
+		result.Matched = result.Matched && rr.Matched
+		result.Requirements = append(result.Requirements, rr)
+	}
+	return result
+}
+
+// nodeSelectorTermMatches reports whether the node matches the term, an invalid
+// term matches no node.
+func nodeSelectorTermMatches(term v1.NodeSelectorTerm, node *v1.Node) bool {
+	selector, err := corev1nodeaffinity.NewNodeSelector(&v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{term}})
+	if err != nil {
+		return false
+	}
+	return selector.Match(node)
+}
+
+// nodeSelectorMismatches formats the requirements the node misses in every term
+// of the selector, none when the node matches a term.
+func nodeSelectorMismatches(selector *v1.NodeSelector, node *v1.Node) []string {
+	terms, _ := evaluateNodeSelector(selector, node)
+	return termMismatches(terms, node)
+}
+
+// nodeAffinityMismatches formats the entries of the node selector and the required
+// node affinity of the pod the node misses in the diff.
+func nodeAffinityMismatches(diff *NodeAffinityDiff, node *v1.Node) []string {
+	mismatches := make([]string, 0)
+	for _, r := range diff.NodeSelector {
+		if !r.Matched {
+			mismatches = append(mismatches, fmt.Sprintf("nodeSelector %s=%s, node has %s", r.Key, r.Values[0], formatNodeLabel(node, r.Key)))
+		}
+	}
+	return append(mismatches, termMismatches(diff.Required, node)...)
+}
+
+// termMismatches formats the requirements the node misses in every evaluated term,
+// none when the node matches a term.
+func termMismatches(terms []NodeSelectorTermResult, node *v1.Node) []string {
+	for _, term := range terms {
+		if term.Matched {
+			return nil
+		}
+	}
+	mismatches := make([]string, 0)
+	for i, term := range terms {
+		if len(term.Requirements) == 0 {
+			mismatches = append(mismatches, fmt.Sprintf("term %d: has no requirement and matches no node", i))
+			continue
+		}
+		for _, r := range term.Requirements {
+			if r.Matched {
+				continue
+			}
+			requirement := v1.NodeSelectorRequirement{Key: r.Key, Operator: v1.NodeSelectorOperator(r.Operator), Values: r.Values}
+			mismatches = append(mismatches, fmt.Sprintf("term %d: %s, node has %s", i, formatNodeSelectorRequirement(requirement), formatNodeLabel(node, r.Key)))
+		}
+	}
+	return mismatches
+}
+
+// failedRequirements counts the requirements the node misses, the ones of the
+// best matching term for the terms. An empty term counts as one.
+func failedRequirements(diff *NodeAffinityDiff) int {
+	failed := 0
+	for _, r := range diff.NodeSelector {
+		if !r.Matched {
+			failed++
+		}
+	}
+	for _, terms := range [][]NodeSelectorTermResult{diff.Required, diff.AddedRequired} {
+		min := -1
+		for _, term := range terms {
+			termFailed := 0
+			if len(term.Requirements) == 0 {
+				termFailed = 1
+			}
+			for _, r := range term.Requirements {
+				if !r.Matched {
+					termFailed++
+				}
+			}
+			if min == -1 || termFailed < min {
+				min = termFailed
+			}
+		}
+		if min > 0 {
+			failed += min
+		}
+	}
+	return failed
+}
+
+func formatNodeAffinity(explanation *NodeAffinityExplanation) string {
+	lines := make([]string, 0)
+	for i, diff := range explanation.Nodes {
+		if i == maxNodeAffinityDiffs {
+			lines = append(lines, fmt.Sprintf("[NodeAffinity] %d more node(s) rejected, see the json or yaml output", len(explanation.Nodes)-maxNodeAffinityDiffs))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("[NodeAffinity] node %s:", diff.Node))
+		if len(diff.NodeSelector) > 0 {
+			lines = append(lines, "  nodeSelector (all must match):")
+			for _, r := range diff.NodeSelector {
+				lines = append(lines, "    "+formatRequirementResult(r))
+			}
+		}
+		lines = append(lines, formatNodeSelectorTermResults("required node affinity", diff.Required)...)
+		lines = append(lines, formatNodeSelectorTermResults("addedAffinity of the profile", diff.AddedRequired)...)
+	}
+
+	if len(explanation.ClosestNodes) > 0 {
+		closest := make([]string, 0, len(explanation.ClosestNodes))
+		for _, n := range explanation.ClosestNodes {
+			closest = append(closest, fmt.Sprintf("%s(%d failed)", n.Name, n.FailedRequirements))
+		}
+		lines = append(lines, fmt.Sprintf("Closest nodes: %s", strings.Join(closest, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatNodeSelectorTermResults(title string, terms []NodeSelectorTermResult) []string {
+	if len(terms) == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("  %s (one term must match):", title)}
+	for i, term := range terms {
+		lines = append(lines, fmt.Sprintf("    %s term %d", passOrFail(term.Matched), i))
+		if len(term.Requirements) == 0 {
+			lines = append(lines, "      has no requirement and matches no node")
+		}
+		for _, r := range term.Requirements {
+			lines = append(lines, "      "+formatRequirementResult(r))
+		}
+	}
+	return lines
+}
+
+func formatRequirementResult(r RequirementResult) string {
+	kind := "label"
+	if r.Field {
+		kind = "field"
+	}
+	nodeValue := fmt.Sprintf("node has no %s", kind)
+	if r.NodeValue != nil {
+		nodeValue = fmt.Sprintf("node %s %s", kind, *r.NodeValue)
+	}
+	requirement := fmt.Sprintf("%s %s", r.Key, r.Operator)
+	switch {
+	case r.Operator == "=" && len(r.Values) == 1:
+		requirement = fmt.Sprintf("%s=%s", r.Key, r.Values[0])
+	case len(r.Values) > 0:
+		requirement += fmt.Sprintf(" [%s]", strings.Join(r.Values, ","))
+	}
+	return fmt.Sprintf("%s %s (%s)", passOrFail(r.Matched), requirement, nodeValue)
+}
+
+func passOrFail(matched bool) string {
+	if matched {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// formatPreferredNodeAffinity prints the preferred terms of the pod and, one row per
+// ranked node, the terms the node matches, the NodeAffinity plugin scores a node by
+// the weights of the terms it matches.
+func formatPreferredNodeAffinity(pod *v1.Pod, nodes []*v1.Node) string {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || len(affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+		return ""
+	}
+	terms := affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+
+	var sb strings.Builder
+	fmt.Fprintln(&sb, "[NodeAffinity] preferred terms:")
+	for i, term := range terms {
+		requirements := make([]string, 0)
+		for _, r := range append(append([]v1.NodeSelectorRequirement{}, term.Preference.MatchExpressions...), term.Preference.MatchFields...) {
+			requirements = append(requirements, formatNodeSelectorRequirement(r))
+		}
+		fmt.Fprintf(&sb, "  term %d (weight %d): %s\n", i, term.Weight, strings.Join(requirements, ","))
+	}
+	w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tNODE\tMATCHED TERMS\tWEIGHT")
+	for rank, n := range nodes {
+		matched := make([]string, 0)
+		var weight int32
+		for i, term := range terms {
+			if evaluateNodeSelectorTerm(term.Preference, n).Matched {
+				matched = append(matched, fmt.Sprint(i))
+				weight += term.Weight
+			}
+		}
+		if len(matched) == 0 {
+			matched = append(matched, "-")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\n", rank+1, n.Name, strings.Join(matched, ","), weight)
+	}
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,121 @@
+package pod
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sync"
+)
+
+// TroubleShootPodScheduleNominator keeps the pods nominated to nodes by preemption,
+// the same way the nominator of kube-scheduler's scheduling queue does.
+type TroubleShootPodScheduleNominator struct {
+	// nominatedPods is a map keyed by a node name and the value is a list of
+	// pods which are nominated to run on the node.
+	nominatedPods map[string][]*framework.PodInfo
+	// nominatedPodToNode is map keyed by a Pod UID to the node name where it is
+	// nominated.
+	nominatedPodToNode map[types.UID]string
+
+	sync.RWMutex
+}
+
+func NewTroubleShootPodScheduleNominator() *TroubleShootPodScheduleNominator {
+	return &TroubleShootPodScheduleNominator{
+		nominatedPods:      make(map[string][]*framework.PodInfo),
+		nominatedPodToNode: make(map[types.UID]string),
+	}
+}
+
+// SetNominatedPods replaces the nominated pods with the pending pods having
+// status.nominatedNodeName set.
+func (npm *TroubleShootPodScheduleNominator) SetNominatedPods(pods []*v1.Pod) {
+	npm.Lock()
+	defer npm.Unlock()
+	npm.nominatedPods = make(map[string][]*framework.PodInfo)
+	npm.nominatedPodToNode = make(map[types.UID]string)
+	for _, p := range pods {
+		if len(p.Spec.NodeName) != 0 || len(p.Status.NominatedNodeName) == 0 {
+			continue
+		}
+		npm.add(framework.NewPodInfo(p), nil)
+	}
+}
+
+func (npm *TroubleShootPodScheduleNominator) AddNominatedPod(pi *framework.PodInfo, nominatingInfo *framework.NominatingInfo) {
+	npm.Lock()
+	defer npm.Unlock()
+	npm.add(pi, nominatingInfo)
+}
+
+func (npm *TroubleShootPodScheduleNominator) DeleteNominatedPodIfExists(pod *v1.Pod) {
+	npm.Lock()
+	defer npm.Unlock()
+	npm.delete(pod)
+}
+
+func (npm *TroubleShootPodScheduleNominator) UpdateNominatedPod(oldPod *v1.Pod, newPodInfo *framework.PodInfo) {
+	npm.Lock()
+	defer npm.Unlock()
+	// In some cases, an Update event with no "NominatedNode" present is received right
+	// after a node("NominatedNode") is reserved for this pod in memory.
+	// In this case, we need to keep reserving the NominatedNode when updating the pod pointer.
+	var nominatingInfo *framework.NominatingInfo
+	if len(oldPod.Status.NominatedNodeName) == 0 && len(newPodInfo.Pod.Status.NominatedNodeName) == 0 {
+		if nnn, ok := npm.nominatedPodToNode[oldPod.UID]; ok {
+			nominatingInfo = &framework.NominatingInfo{NominatingMode: framework.ModeOverride, NominatedNodeName: nnn}
+		}
+	}
+	npm.delete(oldPod)
+	npm.add(newPodInfo, nominatingInfo)
+}
+
+func (npm *TroubleShootPodScheduleNominator) NominatedPodsForNode(nodeName string) []*framework.PodInfo {
+	npm.RLock()
+	defer npm.RUnlock()
+	// Make a copy of the nominated Pods so the caller can mutate safely.
+	pods := make([]*framework.PodInfo, len(npm.nominatedPods[nodeName]))
+	for i := 0; i < len(pods); i++ {
+		pods[i] = npm.nominatedPods[nodeName][i].DeepCopy()
+	}
+	return pods
+}
+
+func (npm *TroubleShootPodScheduleNominator) add(pi *framework.PodInfo, nominatingInfo *framework.NominatingInfo) {
+	// always delete the pod if it already exist, to ensure we never store more than
+	// one instance of the pod.
+	npm.delete(pi.Pod)
+
+	nnn := pi.Pod.Status.NominatedNodeName
+	if nominatingInfo.Mode() == framework.ModeOverride {
+		nnn = nominatingInfo.NominatedNodeName
+	}
+	if len(nnn) == 0 {
+		return
+	}
+
+	npm.nominatedPodToNode[pi.Pod.UID] = nnn
+	for _, npi := range npm.nominatedPods[nnn] {
+		if npi.Pod.UID == pi.Pod.UID {
+			return
+		}
+	}
+	npm.nominatedPods[nnn] = append(npm.nominatedPods[nnn], pi)
+}
+
+func (npm *TroubleShootPodScheduleNominator) delete(p *v1.Pod) {
+	nnn, ok := npm.nominatedPodToNode[p.UID]
+	if !ok {
+		return
+	}
+	for i, np := range npm.nominatedPods[nnn] {
+		if np.Pod.UID == p.UID {
+			npm.nominatedPods[nnn] = append(npm.nominatedPods[nnn][:i], npm.nominatedPods[nnn][i+1:]...)
+			if len(npm.nominatedPods[nnn]) == 0 {
+				delete(npm.nominatedPods, nnn)
+			}
+			break
+		}
+	}
+	delete(npm.nominatedPodToNode, p.UID)
+}
@@ -0,0 +1,89 @@
+package pod
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func nominatedPodNames(npm *TroubleShootPodScheduleNominator, nodeName string) []string {
+	names := make([]string, 0)
+	for _, pi := range npm.NominatedPodsForNode(nodeName) {
+		names = append(names, pi.Pod.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNominator(t *testing.T) {
+	tests := []struct {
+		name string
+		// run changes the nominator holding the pods set by SetNominatedPods.
+		run  func(npm *TroubleShootPodScheduleNominator)
+		want map[string][]string
+	}{
+		{
+			name: "only the pending pods with a nominated node",
+			run:  func(npm *TroubleShootPodScheduleNominator) {},
+			want: map[string][]string{"n1": {"a", "b"}, "n2": {"c"}},
+		},
+		{
+			name: "nominated by preemption",
+			run: func(npm *TroubleShootPodScheduleNominator) {
+				npm.AddNominatedPod(framework.NewPodInfo(newTestPod("p").Obj()), &framework.NominatingInfo{NominatingMode: framework.ModeOverride, NominatedNodeName: "n2"})
+			},
+			want: map[string][]string{"n1": {"a", "b"}, "n2": {"c", "p"}},
+		},
+		{
+			name: "nominated to another node",
+			run: func(npm *TroubleShootPodScheduleNominator) {
+				npm.AddNominatedPod(framework.NewPodInfo(newTestPod("a").NominatedNodeName("n1").Obj()), &framework.NominatingInfo{NominatingMode: framework.ModeOverride, NominatedNodeName: "n2"})
+			},
+			want: map[string][]string{"n1": {"b"}, "n2": {"a", "c"}},
+		},
+		{
+			name: "deleted",
+			run: func(npm *TroubleShootPodScheduleNominator) {
+				npm.DeleteNominatedPodIfExists(newTestPod("c").NominatedNodeName("n2").Obj())
+			},
+			want: map[string][]string{"n1": {"a", "b"}, "n2": {}},
+		},
+		{
+			name: "the update keeps the nomination reserved in memory",
+			run: func(npm *TroubleShootPodScheduleNominator) {
+				npm.AddNominatedPod(framework.NewPodInfo(newTestPod("p").Obj()), &framework.NominatingInfo{NominatingMode: framework.ModeOverride, NominatedNodeName: "n2"})
+				npm.UpdateNominatedPod(newTestPod("p").Obj(), framework.NewPodInfo(newTestPod("p").Obj()))
+			},
+			want: map[string][]string{"n1": {"a", "b"}, "n2": {"c", "p"}},
+		},
+		{
+			name: "replaced by SetNominatedPods",
+			run: func(npm *TroubleShootPodScheduleNominator) {
+				npm.SetNominatedPods([]*v1.Pod{newTestPod("d").NominatedNodeName("n2").Obj()})
+			},
+			want: map[string][]string{"n1": {}, "n2": {"d"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			npm := NewTroubleShootPodScheduleNominator()
+			npm.SetNominatedPods([]*v1.Pod{
+				newTestPod("a").NominatedNodeName("n1").Obj(),
+				newTestPod("b").NominatedNodeName("n1").Obj(),
+				newTestPod("c").NominatedNodeName("n2").Obj(),
+				// Bound pods and pods without a nominated node are not nominated.
+				newTestPod("bound").Node("n1").NominatedNodeName("n1").Obj(),
+				newTestPod("pending").Obj(),
+			})
+			tt.run(npm)
+
+			for nodeName, want := range tt.want {
+				if got := nominatedPodNames(npm, nodeName); !reflect.DeepEqual(got, want) {
+					t.Errorf("node %s: want %v, got %v", nodeName, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/interpodaffinity"
+	"sort"
+	"strings"
+)
+
+const (
+	AffinityTermPodAffinity     = "PodAffinity"
+	AffinityTermPodAntiAffinity = "PodAntiAffinity"
+)
+
+// InterPodAffinityExplanation lists the existing pods behind the required pod
+// affinity and anti-affinity terms the InterPodAffinity plugin rejects nodes for.
+type InterPodAffinityExplanation struct {
+	Terms []AffinityTermExplanation `json:"terms,omitempty"`
+	// ExistingAntiAffinity are the anti-affinity terms of the existing pods matching the pod.
+	ExistingAntiAffinity []AffinityTermExplanation `json:"existingAntiAffinity,omitempty"`
+}
+
+type AffinityTermExplanation struct {
+	Type string `json:"type"`
+	// Owner is the existing pod the term belongs to, nil for the terms of the pod.
+	Owner             *AffinityPod `json:"owner,omitempty"`
+	TopologyKey       string       `json:"topologyKey"`
+	LabelSelector     string       `json:"labelSelector"`
+	Namespaces        []string     `json:"namespaces,omitempty"`
+	NamespaceSelector string       `json:"namespaceSelector,omitempty"`
+	// MatchingPods are the existing pods matching a term of the pod, they satisfy an
+	// affinity term and violate an anti-affinity term in their topology domain. Like
+	// the plugin, only the pods matching all the affinity terms satisfy one.
+	MatchingPods []AffinityPod `json:"matchingPods,omitempty"`
+	// RejectedNodes are the evaluated nodes the term rejects.
+	RejectedNodes []string `json:"rejectedNodes,omitempty"`
+}
+
+type AffinityPod struct {
+	PodReference
+	Node          string `json:"node"`
+	TopologyValue string `json:"topologyValue"`
+}
+
+// explainInterPodAffinity evaluates every required term involving the pod against
+// the pods of the whole snapshot, like the InterPodAffinity plugin does, when it
+// rejects some nodes.
+func (s *ScheduleTroubleShooter) explainInterPodAffinity(report *ScheduleReport) (*InterPodAffinityExplanation, error) {
+	rejected := make([]string, 0)
+	for _, n := range report.Nodes {
+		if failsPlugin(n, interpodaffinity.Name) {
+			rejected = append(rejected, n.Name)
+		}
+	}
+	if len(rejected) == 0 {
+		return nil, nil
+	}
+
+	podInfo := framework.NewPodInfo(s.pod)
+	if podInfo.ParseError != nil {
+		return nil, podInfo.ParseError
+	}
+	nsLister := s.informerFactory.Core().V1().Namespaces().Lister()
+	// The namespace selectors of the terms of the pod are resolved to namespaces.
+	for _, terms := range [][]framework.AffinityTerm{podInfo.RequiredAffinityTerms, podInfo.RequiredAntiAffinityTerms} {
+		for i := range terms {
+			if terms[i].NamespaceSelector.Empty() {
+				continue
+			}
+			namespaces, err := nsLister.List(terms[i].NamespaceSelector)
+			if err != nil {
+				return nil, err
+			}
+			for _, ns := range namespaces {
+				terms[i].Namespaces.Insert(ns.Name)
+			}
+			terms[i].NamespaceSelector = labels.Nothing()
+		}
+	}
+	var nsLabels labels.Set
+	if ns, err := nsLister.Get(s.pod.Namespace); err == nil {
+		nsLabels = labels.Merge(ns.Labels, nil)
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	allNodes, err := s.snapshot.NodeInfos().List()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*v1.Node, len(allNodes))
+	for _, n := range allNodes {
+		nodes[n.Node().Name] = n.Node()
+	}
+
+	explanation := &InterPodAffinityExplanation{}
+	// The plugin only counts the existing pods matching all the affinity terms.
+	seriesPods := make(map[types.UID]bool)
+	if len(podInfo.RequiredAffinityTerms) != 0 {
+		for _, n := range allNodes {
+			for _, pi := range n.Pods {
+				if podMatchesTerms(pi.Pod, podInfo.RequiredAffinityTerms) {
+					seriesPods[pi.Pod.UID] = true
+				}
+			}
+		}
+	}
+	affinityMatches := 0
+	affinityTerms := make([]*AffinityTermExplanation, 0, len(podInfo.RequiredAffinityTerms))
+	for i := range podInfo.RequiredAffinityTerms {
+		term := &podInfo.RequiredAffinityTerms[i]
+		e := newAffinityTermExplanation(AffinityTermPodAffinity, term)
+		e.MatchingPods = matchingAffinityPods(term.TopologyKey, allNodes, func(p *v1.Pod) bool {
+			return seriesPods[p.UID]
+		})
+		affinityMatches += len(e.MatchingPods)
+		affinityTerms = append(affinityTerms, e)
+	}
+	// Like the plugin, the first pod of a series having affinity to itself passes
+	// on the nodes with the topology keys when no pod matches yet.
+	firstOfSeries := affinityMatches == 0 && podMatchesTerms(s.pod, podInfo.RequiredAffinityTerms)
+	for i, e := range affinityTerms {
+		term := &podInfo.RequiredAffinityTerms[i]
+		for _, name := range rejected {
+			value, ok := nodes[name].Labels[term.TopologyKey]
+			if !ok || (!firstOfSeries && !inTopologyDomain(e.MatchingPods, value)) {
+				e.RejectedNodes = append(e.RejectedNodes, name)
+			}
+		}
+		explanation.Terms = append(explanation.Terms, *e)
+	}
+
+	for i := range podInfo.RequiredAntiAffinityTerms {
+		term := &podInfo.RequiredAntiAffinityTerms[i]
+		e := newAffinityTermExplanation(AffinityTermPodAntiAffinity, term)
+		e.MatchingPods = matchingAffinityPods(term.TopologyKey, allNodes, func(p *v1.Pod) bool {
+			return term.Matches(p, nil, false)
+		})
+		for _, name := range rejected {
+			if value, ok := nodes[name].Labels[term.TopologyKey]; ok && inTopologyDomain(e.MatchingPods, value) {
+				e.RejectedNodes = append(e.RejectedNodes, name)
+			}
+		}
+		explanation.Terms = append(explanation.Terms, *e)
+	}
+
+	for _, n := range allNodes {
+		for _, existing := range n.PodsWithRequiredAntiAffinity {
+			for i := range existing.RequiredAntiAffinityTerms {
+				term := &existing.RequiredAntiAffinityTerms[i]
+				value, ok := n.Node().Labels[term.TopologyKey]
+				if !ok || !term.Matches(s.pod, nsLabels, true) {
+					continue
+				}
+				e := newAffinityTermExplanation(AffinityTermPodAntiAffinity, term)
+				e.Owner = &AffinityPod{PodReference: newPodReference(existing.Pod), Node: n.Node().Name, TopologyValue: value}
+				for _, name := range rejected {
+					if v, ok := nodes[name].Labels[term.TopologyKey]; ok && v == value {
+						e.RejectedNodes = append(e.RejectedNodes, name)
+					}
+				}
+				explanation.ExistingAntiAffinity = append(explanation.ExistingAntiAffinity, *e)
+			}
+		}
+	}
+	return explanation, nil
+}
+
+func newAffinityTermExplanation(termType string, term *framework.AffinityTerm) *AffinityTermExplanation {
+	e := &AffinityTermExplanation{
+		Type:          termType,
+		TopologyKey:   term.TopologyKey,
+		LabelSelector: term.Selector.String(),
+		Namespaces:    term.Namespaces.List(),
+	}
+	if !term.NamespaceSelector.Empty() {
+		e.NamespaceSelector = term.NamespaceSelector.String()
+	}
+	return e
+}
+
+// matchingAffinityPods returns the matching pods on the nodes having the topology
+// key, the pods on other nodes don't count for the plugin.
+func matchingAffinityPods(topologyKey string, nodeInfos []*framework.NodeInfo, matches func(*v1.Pod) bool) []AffinityPod {
+	result := make([]AffinityPod, 0)
+	for _, n := range nodeInfos {
+		value, ok := n.Node().Labels[topologyKey]
+		if !ok {
+			continue
+		}
+		for _, pi := range n.Pods {
+			if matches(pi.Pod) {
+				result = append(result, AffinityPod{PodReference: newPodReference(pi.Pod), Node: n.Node().Name, TopologyValue: value})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TopologyValue != result[j].TopologyValue {
+			return result[i].TopologyValue < result[j].TopologyValue
+		}
+		return result[i].Namespace+"/"+result[i].Name < result[j].Namespace+"/"+result[j].Name
+	})
+	return result
+}
+
+func podMatchesTerms(pod *v1.Pod, terms []framework.AffinityTerm) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	for i := range terms {
+		if !terms[i].Matches(pod, nil, false) {
+			return false
+		}
+	}
+	return true
+}
+
+func inTopologyDomain(pods []AffinityPod, value string) bool {
+	for _, p := range pods {
+		if p.TopologyValue == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatInterPodAffinity(explanation *InterPodAffinityExplanation) string {
+	lines := make([]string, 0)
+	for _, e := range explanation.Terms {
+		lines = append(lines, fmt.Sprintf("[InterPodAffinity] %s term %s:", e.Type, formatAffinityTerm(&e)))
+		verb := "satisfied by"
+		if e.Type == AffinityTermPodAntiAffinity {
+			verb = "violated by"
+		}
+		switch {
+		case len(e.MatchingPods) != 0:
+		case e.Type == AffinityTermPodAffinity:
+			lines = append(lines, "    no existing pod matches all the affinity terms of the pod")
+		default:
+			lines = append(lines, "    matches no existing pod")
+		}
+		for _, p := range e.MatchingPods {
+			lines = append(lines, fmt.Sprintf("    %s %s/%s on %s (%s=%s)", verb, p.Namespace, p.Name, p.Node, e.TopologyKey, p.TopologyValue))
+		}
+		if len(e.RejectedNodes) > 0 {
+			lines = append(lines, fmt.Sprintf("    rejects node(s) %s", strings.Join(e.RejectedNodes, ",")))
+		}
+	}
+	for _, e := range explanation.ExistingAntiAffinity {
+		lines = append(lines, fmt.Sprintf("[InterPodAffinity] %s term of existing pod %s/%s on %s (%s=%s) matches the pod: %s",
+			e.Type, e.Owner.Namespace, e.Owner.Name, e.Owner.Node, e.TopologyKey, e.Owner.TopologyValue, formatAffinityTerm(&e)))
+		if len(e.RejectedNodes) > 0 {
+			lines = append(lines, fmt.Sprintf("    rejects node(s) %s", strings.Join(e.RejectedNodes, ",")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatAffinityTerm(e *AffinityTermExplanation) string {
+	namespaces := strings.Join(e.Namespaces, ",")
+	if len(e.NamespaceSelector) != 0 {
+		namespaces = strings.TrimPrefix(namespaces+",selector "+e.NamespaceSelector, ",")
+	}
+	return fmt.Sprintf("topologyKey=%s selector %q namespaces %s", e.TopologyKey, e.LabelSelector, namespaces)
+}
@@ -0,0 +1,171 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/preemption"
+)
+
+// PreemptionDryRunStateKey is the CycleState key the outcome of the preemption
+// dry run is written to by RunPostFilterPlugins.
+const PreemptionDryRunStateKey framework.StateKey = "troubleshooter/PreemptionDryRun"
+
+type PreemptionDryRunResult struct {
+	PluginName        string
+	NominatedNodeName string
+	Victims           []*v1.Pod
+	// VictimsPDBs maps the victims evicted in spite of their PodDisruptionBudget to it.
+	VictimsPDBs map[*v1.Pod]*policy.PodDisruptionBudget
+	// Reason explains why preemption is impossible when no node is nominated.
+	Reason          string
+	NodeToStatusMap framework.NodeToStatusMap
+}
+
+func (r *PreemptionDryRunResult) Clone() framework.StateData {
+	return r
+}
+
+// dryRunPreemption follows the steps of preemption.Evaluator.Preempt, except that
+// the victims are never evicted and every potential node is dry run, instead of
+// the random sample kube-scheduler takes, so the nominated node is deterministic.
+func (f *TroubleShootPodScheduleFilterFramework) dryRunPreemption(
+	ctx context.Context,
+	pluginName string,
+	preemptor preemption.Interface,
+	state *framework.CycleState,
+	pod *v1.Pod,
+	m framework.NodeToStatusMap,
+) (*framework.PostFilterResult, *framework.Status) {
+	result := &PreemptionDryRunResult{PluginName: pluginName}
+	state.Write(PreemptionDryRunStateKey, result)
+
+	if !preemptor.PodEligibleToPreemptOthers(pod, m[pod.Status.NominatedNodeName]) {
+		if pod.Spec.PreemptionPolicy != nil && *pod.Spec.PreemptionPolicy == v1.PreemptNever {
+			result.Reason = "Pod has preemptionPolicy Never"
+		} else {
+			result.Reason = fmt.Sprintf("Pod already preempted pods on its nominated node %s which are still terminating", pod.Status.NominatedNodeName)
+		}
+		return nil, framework.NewStatus(framework.Unschedulable, result.Reason)
+	}
+
+	allNodes, err := f.SnapshotSharedLister().NodeInfos().List()
+	if err != nil {
+		return nil, framework.AsStatus(err)
+	}
+	potentialNodes, unresolvableNodeStatus := nodesWherePreemptionMightHelp(allNodes, m)
+	if len(potentialNodes) == 0 {
+		result.Reason = "Preemption is not helpful for scheduling, no node can be fixed by evicting pods"
+		result.NodeToStatusMap = unresolvableNodeStatus
+		return nil, framework.NewStatus(framework.Unschedulable, result.Reason)
+	}
+
+	pdbs, err := f.listPodDisruptionBudgets()
+	if err != nil {
+		return nil, framework.AsStatus(err)
+	}
+
+	ev := preemption.Evaluator{
+		PluginName: pluginName,
+		Handler:    f,
+		State:      state,
+		Interface:  preemptor,
+	}
+	candidates, nodeStatuses, err := ev.DryRunPreemption(ctx, pod, potentialNodes, pdbs, 0, int32(len(potentialNodes)))
+	for node, nodeStatus := range unresolvableNodeStatus {
+		nodeStatuses[node] = nodeStatus
+	}
+	result.NodeToStatusMap = nodeStatuses
+	if err != nil && len(candidates) == 0 {
+		return nil, framework.AsStatus(err)
+	}
+
+	if len(candidates) == 0 {
+		fitError := &framework.FitError{
+			Pod:         pod,
+			NumAllNodes: len(nodeStatuses),
+			Diagnosis: framework.Diagnosis{
+				NodeToStatusMap: nodeStatuses,
+			},
+		}
+		result.Reason = fitError.Error()
+		return nil, framework.NewStatus(framework.Unschedulable, result.Reason)
+	}
+
+	bestCandidate := ev.SelectCandidate(candidates)
+	if bestCandidate == nil || len(bestCandidate.Name()) == 0 {
+		result.Reason = "No preemption candidate selected"
+		return nil, framework.NewStatus(framework.Unschedulable, result.Reason)
+	}
+
+	result.NominatedNodeName = bestCandidate.Name()
+	result.Victims = bestCandidate.Victims().Pods
+	result.VictimsPDBs = podsWithPDBViolation(result.Victims, pdbs)
+	return framework.NewPostFilterResultWithNominatedNode(bestCandidate.Name()), framework.NewStatus(framework.Success)
+}
+
+func (f *TroubleShootPodScheduleFilterFramework) listPodDisruptionBudgets() ([]*policy.PodDisruptionBudget, error) {
+	return f.SharedInformerFactory().Policy().V1().PodDisruptionBudgets().Lister().List(labels.Everything())
+}
+
+// nodesWherePreemptionMightHelp returns a list of nodes with failed predicates
+// that may be satisfied by removing pods from the node.
+func nodesWherePreemptionMightHelp(nodes []*framework.NodeInfo, m framework.NodeToStatusMap) ([]*framework.NodeInfo, framework.NodeToStatusMap) {
+	var potentialNodes []*framework.NodeInfo
+	nodeStatuses := make(framework.NodeToStatusMap)
+	for _, node := range nodes {
+		name := node.Node().Name
+		// We rely on the status by each plugin - 'Unschedulable' or 'UnschedulableAndUnresolvable'
+		// to determine whether preemption may help or not on the node.
+		if m[name].Code() == framework.UnschedulableAndUnresolvable {
+			nodeStatuses[name] = framework.NewStatus(framework.UnschedulableAndUnresolvable, "Preemption is not helpful for scheduling")
+			continue
+		}
+		potentialNodes = append(potentialNodes, node)
+	}
+	return potentialNodes, nodeStatuses
+}
+
+// podsWithPDBViolation returns the pods whose eviction violates a PodDisruptionBudget,
+// counting the disruptions the same way the DefaultPreemption plugin does.
+func podsWithPDBViolation(pods []*v1.Pod, pdbs []*policy.PodDisruptionBudget) map[*v1.Pod]*policy.PodDisruptionBudget {
+	pdbsAllowed := make([]int32, len(pdbs))
+	for i, pdb := range pdbs {
+		pdbsAllowed[i] = pdb.Status.DisruptionsAllowed
+	}
+
+	violations := make(map[*v1.Pod]*policy.PodDisruptionBudget)
+	for _, pod := range pods {
+		// A pod with no labels will not match any PDB. So, no need to check.
+		if len(pod.Labels) == 0 {
+			continue
+		}
+		for i, pdb := range pdbs {
+			if pdb.Namespace != pod.Namespace {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil {
+				continue
+			}
+			// A PDB with a nil or empty selector matches nothing.
+			if selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			// Existing in DisruptedPods means it has been processed in API server,
+			// we don't treat it as a violating case.
+			if _, exist := pdb.Status.DisruptedPods[pod.Name]; exist {
+				continue
+			}
+			pdbsAllowed[i]--
+			if pdbsAllowed[i] < 0 {
+				violations[pod] = pdb
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,34 @@
+package pod
+
+import (
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+)
+
+// outOfTreeRegistry holds the plugins compiled into the troubleshooter besides the
+// in-tree ones, e.g. the scheduler-plugins bundle built with the schedulerplugins tag.
+var outOfTreeRegistry = frameworkruntime.Registry{}
+
+// outOfTreeRegistryErr is the first error of the plugins registered from init
+// functions, returned when the plugins are looked up rather than panicking at startup.
+var outOfTreeRegistryErr error
+
+// RegisterOutOfTreePlugin makes a plugin not shipped with kube-scheduler available to
+// every scheduler profile, it is meant to be called from init functions.
+func RegisterOutOfTreePlugin(name string, factory frameworkruntime.PluginFactory) error {
+	return outOfTreeRegistry.Register(name, factory)
+}
+
+// outOfTreePlugins merges the registered out-of-tree plugins with the given ones.
+func outOfTreePlugins(registry frameworkruntime.Registry) (frameworkruntime.Registry, error) {
+	if outOfTreeRegistryErr != nil {
+		return nil, outOfTreeRegistryErr
+	}
+	merged := make(frameworkruntime.Registry, len(outOfTreeRegistry)+len(registry))
+	if err := merged.Merge(outOfTreeRegistry); err != nil {
+		return nil, err
+	}
+	if err := merged.Merge(registry); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
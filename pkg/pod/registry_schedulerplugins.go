@@ -0,0 +1,38 @@
+//go:build schedulerplugins
+// +build schedulerplugins
+
+package pod
+
+import (
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	// Registers the args of the plugins, so the scheduler config decodes them.
+	_ "sigs.k8s.io/scheduler-plugins/apis/config/scheme"
+	"sigs.k8s.io/scheduler-plugins/pkg/capacityscheduling"
+	"sigs.k8s.io/scheduler-plugins/pkg/coscheduling"
+	"sigs.k8s.io/scheduler-plugins/pkg/noderesourcetopology"
+	"sigs.k8s.io/scheduler-plugins/pkg/trimaran/loadvariationriskbalancing"
+	"sigs.k8s.io/scheduler-plugins/pkg/trimaran/targetloadpacking"
+)
+
+// init registers the common plugins of scheduler-plugins, matching the kube-scheduler
+// release of the troubleshooter, when built with
+//
+//	go build -tags schedulerplugins
+//
+// go.mod pins sigs.k8s.io/scheduler-plugins, and k8s.io/kubernetes and its staging
+// modules to the release scheduler-plugins is built against. Coscheduling and CapacityScheduling list
+// their CRDs from the cluster, so they can't run offline from dumped manifests.
+func init() {
+	plugins := frameworkruntime.Registry{
+		capacityscheduling.Name:         capacityscheduling.New,
+		coscheduling.Name:               coscheduling.New,
+		noderesourcetopology.Name:       noderesourcetopology.New,
+		loadvariationriskbalancing.Name: loadvariationriskbalancing.New,
+		targetloadpacking.Name:          targetloadpacking.New,
+	}
+	for name, factory := range plugins {
+		if err := RegisterOutOfTreePlugin(name, factory); err != nil && outOfTreeRegistryErr == nil {
+			outOfTreeRegistryErr = err
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build schedulerplugins
+// +build schedulerplugins
+
+package pod
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sigs.k8s.io/scheduler-plugins/apis/config"
+	"sigs.k8s.io/scheduler-plugins/pkg/coscheduling"
+	"testing"
+	"troubleshooter/pkg"
+)
+
+func TestSchedulerPluginsArgsDecode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`apiVersion: kubescheduler.config.k8s.io/v1beta3
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+  plugins:
+    multiPoint:
+      enabled:
+      - name: Coscheduling
+  pluginConfig:
+  - name: Coscheduling
+    args:
+      permitWaitingTimeSeconds: 17
+`)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := pkg.LoadSchedulerConfigByPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pc := range cfg.Profiles[0].PluginConfig {
+		if pc.Name != coscheduling.Name {
+			continue
+		}
+		args, ok := pc.Args.(*config.CoschedulingArgs)
+		if !ok {
+			t.Fatalf("want the args decoded as CoschedulingArgs, got %T", pc.Args)
+		}
+		if args.PermitWaitingTimeSeconds != 17 {
+			t.Errorf("want permitWaitingTimeSeconds 17, got %d", args.PermitWaitingTimeSeconds)
+		}
+		return
+	}
+	t.Fatalf("no args for %s", coscheduling.Name)
+}
@@ -0,0 +1,311 @@
+package pod
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ScheduleReportAPIVersion is bumped on every incompatible change of ScheduleReport,
+// so the consumers of the json and yaml outputs can rely on its schema.
+const ScheduleReportAPIVersion = "troubleshooter/v1alpha1"
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+type Verdict string
+
+const (
+	VerdictSchedulable      Verdict = "Schedulable"
+	VerdictUnschedulable    Verdict = "Unschedulable"
+	VerdictAlreadyScheduled Verdict = "AlreadyScheduled"
+	// VerdictDeleted ends the watch of a pod deleted or terminated before it was bound.
+	VerdictDeleted Verdict = "Deleted"
+)
+
+// ScheduleReport is the outcome of troubleshooting the schedule of a pod.
+type ScheduleReport struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Pod        PodReference `json:"pod"`
+	// Node is the node the pod is evaluated against, empty when every node is.
+	Node    string  `json:"node,omitempty"`
+	Profile string  `json:"profile"`
+	Verdict Verdict `json:"verdict"`
+	Message string  `json:"message"`
+
+	PreFilter  *PluginResult     `json:"preFilter,omitempty"`
+	Nodes      []NodeResult      `json:"nodes,omitempty"`
+	Preemption *PreemptionResult `json:"preemption,omitempty"`
+	// Volumes explains the claims of the pod excluding nodes.
+	Volumes []VolumeDiagnosis `json:"volumes,omitempty"`
+	// TopologySpread explains the topology spread constraints of the pod.
+	TopologySpread []TopologySpreadExplanation `json:"topologySpread,omitempty"`
+	// InterPodAffinity lists the pods behind the pod affinity terms rejecting nodes.
+	InterPodAffinity *InterPodAffinityExplanation `json:"interPodAffinity,omitempty"`
+	// NodeAffinity diffs the node affinity of the pod with the nodes rejecting it.
+	NodeAffinity *NodeAffinityExplanation `json:"nodeAffinity,omitempty"`
+	// Suggestions are the remediations of the failures of the filter plugins.
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+	// Scheduler is what kube-scheduler reported for the pod, compared with the simulation.
+	Scheduler *SchedulerComparison `json:"scheduler,omitempty"`
+
+	SkippedPlugins []string `json:"skippedPlugins,omitempty"`
+}
+
+type PodReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type PrioritizedPodReference struct {
+	PodReference
+	Priority int32 `json:"priority"`
+}
+
+type PluginResult struct {
+	Code         string   `json:"code"`
+	FailedPlugin string   `json:"failedPlugin,omitempty"`
+	Reasons      []string `json:"reasons,omitempty"`
+}
+
+type PluginStatus struct {
+	Plugin  string   `json:"plugin"`
+	Code    string   `json:"code"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type NodeResult struct {
+	Name         string         `json:"name"`
+	Fit          bool           `json:"fit"`
+	FailedPlugin string         `json:"failedPlugin,omitempty"`
+	Reasons      []string       `json:"reasons,omitempty"`
+	Plugins      []PluginStatus `json:"plugins,omitempty"`
+	// ReservedFor lists the nominated pods the node is only unavailable because of.
+	ReservedFor []PrioritizedPodReference `json:"reservedFor,omitempty"`
+	// Resources breaks down the resources of the node when NodeResourcesFit fails.
+	Resources *ResourceFit `json:"resources,omitempty"`
+}
+
+type PreemptionResult struct {
+	Plugin        string   `json:"plugin,omitempty"`
+	Possible      bool     `json:"possible"`
+	NominatedNode string   `json:"nominatedNode,omitempty"`
+	Victims       []Victim `json:"victims,omitempty"`
+	Reason        string   `json:"reason,omitempty"`
+}
+
+type Victim struct {
+	PrioritizedPodReference
+	// PodDisruptionBudget is the namespace/name of the budget the eviction violates.
+	PodDisruptionBudget string `json:"podDisruptionBudget,omitempty"`
+}
+
+func newScheduleReport(pod *v1.Pod, nodeName, profileName string) *ScheduleReport {
+	return &ScheduleReport{
+		APIVersion: ScheduleReportAPIVersion,
+		Kind:       "ScheduleReport",
+		Pod:        newPodReference(pod),
+		Node:       nodeName,
+		Profile:    profileName,
+	}
+}
+
+func newPodReference(pod *v1.Pod) PodReference {
+	return PodReference{Namespace: pod.Namespace, Name: pod.Name}
+}
+
+func newPrioritizedPodReferences(pods []*v1.Pod) []PrioritizedPodReference {
+	refs := make([]PrioritizedPodReference, 0, len(pods))
+	for _, p := range pods {
+		refs = append(refs, PrioritizedPodReference{PodReference: newPodReference(p), Priority: corev1helpers.PodPriority(p)})
+	}
+	return refs
+}
+
+func newPluginResult(status *framework.Status) *PluginResult {
+	// A nil status is a success.
+	if status == nil {
+		return &PluginResult{Code: framework.Success.String()}
+	}
+	return &PluginResult{
+		Code:         status.Code().String(),
+		FailedPlugin: status.FailedPlugin(),
+		Reasons:      status.Reasons(),
+	}
+}
+
+// newPluginStatuses sorts the statuses by plugin name, so the output is stable.
+func newPluginStatuses(statuses framework.PluginToStatus) []PluginStatus {
+	result := make([]PluginStatus, 0, len(statuses))
+	for pl, status := range statuses {
+		result = append(result, PluginStatus{Plugin: pl, Code: status.Code().String(), Reasons: status.Reasons()})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Plugin < result[j].Plugin
+	})
+	return result
+}
+
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("Unknown output format %s, supported formats: %s,%s,%s\n", format, OutputTable, OutputJSON, OutputYAML)
+}
+
+func PrintScheduleReport(w io.Writer, format string, report *ScheduleReport) error {
+	return printReport(w, format, report, func() string {
+		return formatScheduleReport(report)
+	})
+}
+
+// printReport marshals the report as json or yaml, or prints it as formatted by formatTable.
+func printReport(w io.Writer, format string, report interface{}, formatTable func() string) error {
+	switch format {
+	case OutputJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case OutputYAML:
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OutputTable:
+		_, err := fmt.Fprintln(w, formatTable())
+		return err
+	}
+	return ValidateOutputFormat(format)
+}
+
+func formatScheduleReport(report *ScheduleReport) string {
+	var sb strings.Builder
+	switch {
+	case report.Verdict == VerdictAlreadyScheduled:
+		fmt.Fprint(&sb, report.Message)
+	case len(report.Node) != 0:
+		formatNodeVerdict(&sb, report)
+	default:
+		formatClusterVerdict(&sb, report)
+	}
+
+	for _, n := range report.Nodes {
+		if n.Resources != nil {
+			fmt.Fprintf(&sb, "\n%s", formatResourceFit(n.Name, n.Resources))
+		}
+	}
+	if len(report.Volumes) > 0 {
+		fmt.Fprintf(&sb, "\n%s", formatVolumeDiagnoses(report.Volumes))
+	}
+	if len(report.TopologySpread) > 0 {
+		fmt.Fprintf(&sb, "\n%s", formatTopologySpread(report.TopologySpread))
+	}
+	if report.InterPodAffinity != nil {
+		fmt.Fprintf(&sb, "\n%s", formatInterPodAffinity(report.InterPodAffinity))
+	}
+	if report.NodeAffinity != nil {
+		fmt.Fprintf(&sb, "\n%s", formatNodeAffinity(report.NodeAffinity))
+	}
+	if report.Preemption != nil {
+		fmt.Fprintf(&sb, "\n%s", formatPreemption(report.Preemption))
+	}
+	if len(report.Suggestions) > 0 {
+		fmt.Fprintf(&sb, "\n%s", formatSuggestions(report.Suggestions))
+	}
+	if report.Scheduler != nil {
+		fmt.Fprintf(&sb, "\n%s", formatSchedulerComparison(report.Scheduler))
+	}
+	if len(report.SkippedPlugins) > 0 {
+		fmt.Fprintf(&sb, "\nSkipped plugins disabled by profile %s: %s", report.Profile, strings.Join(report.SkippedPlugins, ","))
+	}
+	return sb.String()
+}
+
+func formatNodeVerdict(sb *strings.Builder, report *ScheduleReport) {
+	if report.Verdict == VerdictSchedulable {
+		fmt.Fprintf(sb, "[Success] %s", report.Message)
+		return
+	}
+
+	plgStatusList := make([]string, 0)
+	if report.PreFilter != nil && len(report.PreFilter.FailedPlugin) != 0 {
+		plgStatusList = append(plgStatusList, fmt.Sprintf("%s: %s", report.PreFilter.FailedPlugin, strings.Join(report.PreFilter.Reasons, ",")))
+	}
+	for _, n := range report.Nodes {
+		for _, pl := range n.Plugins {
+			plgStatusList = append(plgStatusList, fmt.Sprintf("%s: %s", pl.Plugin, strings.Join(pl.Reasons, ",")))
+		}
+		if len(n.ReservedFor) > 0 {
+			plgStatusList = append(plgStatusList, fmt.Sprintf("Node %s is only unavailable because it is reserved for nominated pods %s", n.Name, formatPrioritizedPods(n.ReservedFor)))
+		}
+	}
+	fmt.Fprintf(sb, "[Fail] %s\n%s", report.Message, strings.Join(plgStatusList, "\n"))
+}
+
+func formatClusterVerdict(sb *strings.Builder, report *ScheduleReport) {
+	w := tabwriter.NewWriter(sb, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tVERDICT\tREASONS")
+	for _, n := range report.Nodes {
+		if n.Fit {
+			fmt.Fprintf(w, "%s\t%s\t\n", n.Name, "Fit")
+			continue
+		}
+		reasons := strings.Join(n.Reasons, ",")
+		if len(n.ReservedFor) > 0 {
+			reasons += fmt.Sprintf(" (only because reserved for nominated pods %s)", formatPrioritizedPods(n.ReservedFor))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", n.Name, "NoFit", reasons)
+	}
+	w.Flush()
+
+	if report.Verdict == VerdictSchedulable {
+		fmt.Fprintf(sb, "[Success] %s", report.Message)
+	} else {
+		fmt.Fprintf(sb, "[Fail] %s", report.Message)
+	}
+}
+
+func formatPreemption(result *PreemptionResult) string {
+	if !result.Possible {
+		return fmt.Sprintf("[Preemption] Impossible: %s", result.Reason)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Preemption] %s would nominate node %s by evicting %d pod(s):\n", result.Plugin, result.NominatedNode, len(result.Victims))
+	w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "VICTIM\tPRIORITY\tPDB VIOLATION")
+	for _, victim := range result.Victims {
+		violation := victim.PodDisruptionBudget
+		if len(violation) == 0 {
+			violation = "-"
+		}
+		fmt.Fprintf(w, "%s/%s\t%d\t%s\n", victim.Namespace, victim.Name, victim.Priority, violation)
+	}
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func formatPrioritizedPods(pods []PrioritizedPodReference) string {
+	names := make([]string, 0, len(pods))
+	for _, p := range pods {
+		names = append(names, fmt.Sprintf("%s/%s(priority %d)", p.Namespace, p.Name, p.Priority))
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,232 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxTopConsumers bounds the pods listed as the top consumers of a node.
+const maxTopConsumers = 5
+
+// ResourceFit breaks down the resources of a node the NodeResourcesFit plugin
+// rejects the pod on.
+type ResourceFit struct {
+	Resources    []ResourceUsage  `json:"resources"`
+	TopConsumers []PodConsumption `json:"topConsumers,omitempty"`
+}
+
+// ResourceUsage is the usage of a resource of the node, Requested is the sum
+// of the requests of the pods on the node and PodRequest the one of the pod.
+type ResourceUsage struct {
+	Name        v1.ResourceName   `json:"name"`
+	Allocatable resource.Quantity `json:"allocatable"`
+	Requested   resource.Quantity `json:"requested"`
+	PodRequest  resource.Quantity `json:"podRequest"`
+	// Shortfall is how much the pod request exceeds the free resource, zero when it fits.
+	Shortfall resource.Quantity `json:"shortfall"`
+}
+
+// PodConsumption is the request of a pod on the node for the resources of the breakdown.
+type PodConsumption struct {
+	PrioritizedPodReference
+	Requests v1.ResourceList `json:"requests"`
+}
+
+// addResourceFits breaks down the resources of the nodes the NodeResourcesFit plugin fails on.
+func addResourceFits(pod *v1.Pod, nodes []NodeResult, nodeInfos []*framework.NodeInfo) {
+	nodeInfoMap := newNodeInfoMap(nodeInfos)
+	for i := range nodes {
+		nodeInfo, ok := nodeInfoMap[nodes[i].Name]
+		if !ok {
+			continue
+		}
+		for _, pl := range nodes[i].Plugins {
+			if pl.Plugin == noderesources.FitName {
+				nodes[i].Resources = newResourceFit(pod, nodeInfo)
+			}
+		}
+	}
+}
+
+func newNodeInfoMap(nodeInfos []*framework.NodeInfo) map[string]*framework.NodeInfo {
+	nodeInfoMap := make(map[string]*framework.NodeInfo, len(nodeInfos))
+	for _, ni := range nodeInfos {
+		nodeInfoMap[ni.Node().Name] = ni
+	}
+	return nodeInfoMap
+}
+
+// newResourceFit computes the breakdown of cpu, memory, ephemeral-storage, the pods
+// count and the extended resources the pod requests on the node, and the pods
+// requesting the most of the insufficient resources.
+func newResourceFit(pod *v1.Pod, nodeInfo *framework.NodeInfo) *ResourceFit {
+	podRequest := podResourceRequest(pod)
+	names := []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourceEphemeralStorage, v1.ResourcePods}
+	scalarNames := make([]string, 0, len(podRequest.ScalarResources))
+	for name := range podRequest.ScalarResources {
+		scalarNames = append(scalarNames, string(name))
+	}
+	sort.Strings(scalarNames)
+	for _, name := range scalarNames {
+		names = append(names, v1.ResourceName(name))
+	}
+
+	fit := &ResourceFit{}
+	insufficient := make([]v1.ResourceName, 0)
+	for _, name := range names {
+		allocatable := resourceValue(nodeInfo.Allocatable, name)
+		requested := resourceValue(nodeInfo.Requested, name)
+		if name == v1.ResourcePods {
+			requested = int64(len(nodeInfo.Pods))
+		}
+		request := resourceValue(podRequest, name)
+		shortfall := request + requested - allocatable
+		if shortfall > 0 {
+			insufficient = append(insufficient, name)
+		} else {
+			shortfall = 0
+		}
+		fit.Resources = append(fit.Resources, ResourceUsage{
+			Name:        name,
+			Allocatable: resourceQuantity(name, allocatable),
+			Requested:   resourceQuantity(name, requested),
+			PodRequest:  resourceQuantity(name, request),
+			Shortfall:   resourceQuantity(name, shortfall),
+		})
+	}
+	fit.TopConsumers = topConsumers(nodeInfo, insufficient)
+	return fit
+}
+
+// topConsumers returns the pods of the node with the largest requests of the
+// resources, compared as shares of the allocatable of the node.
+func topConsumers(nodeInfo *framework.NodeInfo, names []v1.ResourceName) []PodConsumption {
+	if len(names) == 0 {
+		names = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+	}
+	share := func(r *framework.Resource) float64 {
+		max := 0.0
+		for _, name := range names {
+			allocatable := resourceValue(nodeInfo.Allocatable, name)
+			if allocatable <= 0 {
+				continue
+			}
+			if s := float64(resourceValue(r, name)) / float64(allocatable); s > max {
+				max = s
+			}
+		}
+		return max
+	}
+
+	type consumer struct {
+		pod     *v1.Pod
+		request *framework.Resource
+	}
+	consumers := make([]consumer, 0, len(nodeInfo.Pods))
+	for _, pi := range nodeInfo.Pods {
+		consumers = append(consumers, consumer{pod: pi.Pod, request: podResourceRequest(pi.Pod)})
+	}
+	sort.SliceStable(consumers, func(i, j int) bool {
+		return share(consumers[i].request) > share(consumers[j].request)
+	})
+
+	result := make([]PodConsumption, 0, maxTopConsumers)
+	for _, c := range consumers {
+		if len(result) == maxTopConsumers || share(c.request) == 0 {
+			break
+		}
+		requests := make(v1.ResourceList, len(names))
+		for _, name := range names {
+			requests[name] = resourceQuantity(name, resourceValue(c.request, name))
+		}
+		result = append(result, PodConsumption{
+			PrioritizedPodReference: PrioritizedPodReference{PodReference: newPodReference(c.pod), Priority: corev1helpers.PodPriority(c.pod)},
+			Requests:                requests,
+		})
+	}
+	return result
+}
+
+// podResourceRequest computes the request of the pod like the NodeResourcesFit
+// plugin does, the max of the containers sum and the init containers, plus the
+// overhead. The pods resource counts the pod itself.
+func podResourceRequest(pod *v1.Pod) *framework.Resource {
+	result := &framework.Resource{AllowedPodNumber: 1}
+	for _, c := range pod.Spec.Containers {
+		result.Add(c.Resources.Requests)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		result.SetMaxResource(c.Resources.Requests)
+	}
+	if pod.Spec.Overhead != nil {
+		result.Add(pod.Spec.Overhead)
+	}
+	return result
+}
+
+func resourceValue(r *framework.Resource, name v1.ResourceName) int64 {
+	switch name {
+	case v1.ResourceCPU:
+		return r.MilliCPU
+	case v1.ResourceMemory:
+		return r.Memory
+	case v1.ResourceEphemeralStorage:
+		return r.EphemeralStorage
+	case v1.ResourcePods:
+		return int64(r.AllowedPodNumber)
+	}
+	return r.ScalarResources[name]
+}
+
+// resourceQuantity turns the value of the resource back into a quantity, cpu is in millicores.
+func resourceQuantity(name v1.ResourceName, value int64) resource.Quantity {
+	switch {
+	case name == v1.ResourceCPU:
+		return *resource.NewMilliQuantity(value, resource.DecimalSI)
+	case name == v1.ResourceMemory, name == v1.ResourceEphemeralStorage, v1helper.IsHugePageResourceName(name):
+		return *resource.NewQuantity(value, resource.BinarySI)
+	}
+	return *resource.NewQuantity(value, resource.DecimalSI)
+}
+
+func formatResourceValue(name v1.ResourceName, value int64) string {
+	q := resourceQuantity(name, value)
+	return q.String()
+}
+
+func formatResourceFit(node string, fit *ResourceFit) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Resources] node %s:\n", node)
+	w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tALLOCATABLE\tREQUESTED\tPOD REQUEST\tSHORTFALL")
+	for _, r := range fit.Resources {
+		shortfall := "-"
+		if !r.Shortfall.IsZero() {
+			shortfall = r.Shortfall.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Allocatable.String(), r.Requested.String(), r.PodRequest.String(), shortfall)
+	}
+	w.Flush()
+
+	if len(fit.TopConsumers) > 0 {
+		consumers := make([]string, 0, len(fit.TopConsumers))
+		for _, c := range fit.TopConsumers {
+			requests := make([]string, 0, len(c.Requests))
+			for name, q := range c.Requests {
+				requests = append(requests, fmt.Sprintf("%s %s", name, q.String()))
+			}
+			sort.Strings(requests)
+			consumers = append(consumers, fmt.Sprintf("%s/%s(priority %d, %s)", c.Namespace, c.Name, c.Priority, strings.Join(requests, ",")))
+		}
+		fmt.Fprintf(&sb, "Top consumers: %s\n", strings.Join(consumers, ", "))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,143 @@
+package pod
+
+import (
+	v1 "k8s.io/api/core/v1"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+	"reflect"
+	"testing"
+)
+
+const testGPU v1.ResourceName = "example.com/gpu"
+
+func TestNewResourceFit(t *testing.T) {
+	node := st.MakeNode().Name("n1").Capacity(map[v1.ResourceName]string{
+		v1.ResourceCPU:    "2",
+		v1.ResourceMemory: "4Gi",
+		v1.ResourcePods:   "3",
+		testGPU:           "1",
+	}).Obj()
+
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		pods []*v1.Pod
+		// wantShortfalls are the non-zero shortfalls.
+		wantShortfalls map[v1.ResourceName]string
+		wantTop        []string
+	}{
+		{
+			name: "cpu shortfall",
+			pod:  newTestPod("p").Req(map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"}).Obj(),
+			pods: []*v1.Pod{
+				newTestPod("small").Req(map[v1.ResourceName]string{v1.ResourceCPU: "300m"}).Obj(),
+				newTestPod("big").Req(map[v1.ResourceName]string{v1.ResourceCPU: "1200m"}).Obj(),
+			},
+			wantShortfalls: map[v1.ResourceName]string{v1.ResourceCPU: "500m"},
+			wantTop:        []string{"big", "small"},
+		},
+		{
+			name: "fits",
+			pod:  newTestPod("p").Req(map[v1.ResourceName]string{v1.ResourceCPU: "500m"}).Obj(),
+			pods: []*v1.Pod{
+				newTestPod("small").Req(map[v1.ResourceName]string{v1.ResourceCPU: "300m"}).Obj(),
+			},
+			wantShortfalls: map[v1.ResourceName]string{},
+			wantTop:        []string{"small"},
+		},
+		{
+			name: "pods count, the pod counts one",
+			pod:  newTestPod("p").Obj(),
+			pods: []*v1.Pod{
+				newTestPod("a").Obj(),
+				newTestPod("b").Obj(),
+				newTestPod("c").Obj(),
+			},
+			wantShortfalls: map[v1.ResourceName]string{v1.ResourcePods: "1"},
+			// Every pod takes one of the pods of the node.
+			wantTop: []string{"a", "b", "c"},
+		},
+		{
+			name: "extended resource",
+			pod:  newTestPod("p").Req(map[v1.ResourceName]string{testGPU: "1"}).Obj(),
+			pods: []*v1.Pod{
+				newTestPod("trainer").Req(map[v1.ResourceName]string{testGPU: "1"}).Obj(),
+				newTestPod("web").Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Obj(),
+			},
+			wantShortfalls: map[v1.ResourceName]string{testGPU: "1"},
+			wantTop:        []string{"trainer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := newResourceFit(tt.pod, newTestNodeInfo(node, tt.pods...))
+
+			shortfalls := make(map[v1.ResourceName]string)
+			for _, r := range fit.Resources {
+				if !r.Shortfall.IsZero() {
+					shortfalls[r.Name] = r.Shortfall.String()
+				}
+			}
+			if !reflect.DeepEqual(shortfalls, tt.wantShortfalls) {
+				t.Errorf("shortfalls: want %v, got %v", tt.wantShortfalls, shortfalls)
+			}
+
+			top := make([]string, 0, len(fit.TopConsumers))
+			for _, c := range fit.TopConsumers {
+				top = append(top, c.Name)
+			}
+			if !reflect.DeepEqual(top, tt.wantTop) {
+				t.Errorf("top consumers: want %v, got %v", tt.wantTop, top)
+			}
+		})
+	}
+}
+
+func TestTopConsumers(t *testing.T) {
+	node := st.MakeNode().Name("n1").Capacity(map[v1.ResourceName]string{
+		v1.ResourceCPU:    "4",
+		v1.ResourceMemory: "8Gi",
+	}).Obj()
+	pods := []*v1.Pod{
+		// A quarter of the cpu.
+		newTestPod("cpu").Req(map[v1.ResourceName]string{v1.ResourceCPU: "1"}).Obj(),
+		// Half of the memory.
+		newTestPod("memory").Req(map[v1.ResourceName]string{v1.ResourceMemory: "4Gi"}).Obj(),
+		newTestPod("idle").Obj(),
+	}
+	for i := 0; i < maxTopConsumers; i++ {
+		pods = append(pods, newTestPod("tiny-"+string(rune('a'+i))).Req(map[v1.ResourceName]string{v1.ResourceCPU: "10m"}).Obj())
+	}
+	nodeInfo := newTestNodeInfo(node, pods...)
+
+	tests := []struct {
+		name      string
+		resources []v1.ResourceName
+		want      []string
+	}{
+		{
+			name:      "compared as shares of the allocatable",
+			resources: []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory},
+			want:      []string{"memory", "cpu", "tiny-a", "tiny-b", "tiny-c"},
+		},
+		{
+			name:      "only the insufficient resource",
+			resources: []v1.ResourceName{v1.ResourceMemory},
+			want:      []string{"memory"},
+		},
+		{
+			name: "cpu and memory by default",
+			want: []string{"memory", "cpu", "tiny-a", "tiny-b", "tiny-c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, c := range topConsumers(nodeInfo, tt.resources) {
+				got = append(got, c.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
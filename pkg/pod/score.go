@@ -0,0 +1,258 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"github.com/briandowns/spinner"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type nodeScore struct {
+	name          string
+	total         int64
+	pluginsScores map[string]int64
+}
+
+// ScoreReport ranks the feasible nodes of the pod by their weighted scores.
+type ScoreReport struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Pod        PodReference `json:"pod"`
+	Profile    string       `json:"profile"`
+	Verdict    Verdict      `json:"verdict"`
+	Message    string       `json:"message"`
+	// AssignedNode is the node the pod is already on, empty for a pending pod.
+	AssignedNode string `json:"assignedNode,omitempty"`
+	NumAllNodes  int    `json:"numAllNodes"`
+	// ScorePlugins are the score plugins and the extenders with their weights.
+	ScorePlugins []ScorePluginWeight `json:"scorePlugins,omitempty"`
+	Nodes        []NodeScore         `json:"nodes,omitempty"`
+	// Differences are the plugins the first node outscores the assigned node on.
+	Differences []string `json:"differences,omitempty"`
+
+	// pod and rankedNodes are kept for the preferred node affinity table.
+	pod         *v1.Pod
+	rankedNodes []*v1.Node
+}
+
+type ScorePluginWeight struct {
+	Name   string `json:"name"`
+	Weight int32  `json:"weight"`
+}
+
+// NodeScore is the weighted score of every score plugin for a feasible node.
+type NodeScore struct {
+	Name    string           `json:"name"`
+	Rank    int              `json:"rank"`
+	Total   int64            `json:"total"`
+	Plugins map[string]int64 `json:"plugins"`
+}
+
+// ExecuteScore ranks the feasible nodes for the pod the way kube-scheduler's
+// prioritizeNodes does, explaining where the pod would land and why.
+func (s *ScheduleTroubleShooter) ExecuteScore() (Verdict, error) {
+	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	sp.Start()
+
+	ctx := context.Background()
+	report, err := s.scoreCore(ctx)
+	sp.Stop()
+	if err != nil {
+		return "", err
+	}
+	err = printReport(os.Stdout, s.outputFormat, report, func() string {
+		return formatScoreReport(report)
+	})
+	if err != nil {
+		return "", err
+	}
+	return report.Verdict, nil
+}
+
+func (s *ScheduleTroubleShooter) scoreCore(ctx context.Context) (*ScoreReport, error) {
+	fw := s.framework
+
+	pod := s.pod
+	assignedNodeName := pod.Spec.NodeName
+	if len(assignedNodeName) != 0 {
+		// Score the pod as if it were still pending, otherwise it competes
+		// with itself for the resources of the node it landed on.
+		if err := s.removeAssignedPod(); err != nil {
+			return nil, err
+		}
+		pod = pod.DeepCopy()
+		pod.Spec.NodeName = ""
+	}
+	report := &ScoreReport{
+		APIVersion:   ScheduleReportAPIVersion,
+		Kind:         "ScoreReport",
+		Pod:          newPodReference(s.pod),
+		Profile:      fw.ProfileName(),
+		AssignedNode: assignedNodeName,
+		NumAllNodes:  len(s.nodeInfos),
+		pod:          pod,
+	}
+
+	state := framework.NewCycleState()
+	result, err := findNodesThatFitPod(ctx, fw, state, pod, s.nodeInfos)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.feasibleNodes) == 0 {
+		fitErr := &framework.FitError{
+			Pod:         pod,
+			NumAllNodes: len(s.nodeInfos),
+			Diagnosis:   result.diagnosis,
+		}
+		report.Verdict = VerdictUnschedulable
+		report.Message = fitErr.Error()
+		return report, nil
+	}
+
+	scores, err := prioritizeNodes(ctx, fw, state, pod, result.feasibleNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Verdict = VerdictSchedulable
+	if len(assignedNodeName) != 0 {
+		report.Verdict = VerdictAlreadyScheduled
+	}
+	scorePlugins := append(fw.ListPlugins().Score.Enabled, extenderScoreColumns(s.schedulerConfig)...)
+	for _, pl := range scorePlugins {
+		report.ScorePlugins = append(report.ScorePlugins, ScorePluginWeight{Name: pl.Name, Weight: pl.Weight})
+	}
+	for i, score := range scores {
+		report.Nodes = append(report.Nodes, NodeScore{Name: score.name, Rank: i + 1, Total: score.total, Plugins: score.pluginsScores})
+	}
+	report.Message, report.Differences = scoreConclusion(pod, assignedNodeName, scorePlugins, scores)
+
+	nodeInfoMap := newNodeInfoMap(result.feasibleNodes)
+	for _, score := range scores {
+		report.rankedNodes = append(report.rankedNodes, nodeInfoMap[score.name].Node())
+	}
+	return report, nil
+}
+
+// prioritizeNodes runs the PreScore and Score plugins and the extenders against
+// the feasible nodes, ranking them from the best to the worst.
+func prioritizeNodes(ctx context.Context, fw framework.Framework, state *framework.CycleState, pod *v1.Pod, feasibleNodes []*framework.NodeInfo) ([]*nodeScore, error) {
+	nodes := make([]*v1.Node, 0, len(feasibleNodes))
+	for _, ni := range feasibleNodes {
+		nodes = append(nodes, ni.Node())
+	}
+
+	preScoreStatus := fw.RunPreScorePlugins(ctx, state, pod, nodes)
+	if !preScoreStatus.IsSuccess() {
+		return nil, preScoreStatus.AsError()
+	}
+
+	pluginToNodeScores, scoreStatus := fw.RunScorePlugins(ctx, state, pod, nodes)
+	if !scoreStatus.IsSuccess() {
+		return nil, scoreStatus.AsError()
+	}
+
+	extenderToNodeScores := runExtenderPrioritizers(fw.Extenders(), pod, nodes)
+
+	scores := make([]*nodeScore, len(nodes))
+	for i, n := range nodes {
+		scores[i] = &nodeScore{name: n.Name, pluginsScores: make(map[string]int64)}
+		for pl, nodeScoreList := range pluginToNodeScores {
+			scores[i].pluginsScores[pl] = nodeScoreList[i].Score
+			scores[i].total += nodeScoreList[i].Score
+		}
+		for extender, nodeScores := range extenderToNodeScores {
+			scores[i].pluginsScores[extender] += nodeScores[n.Name]
+			scores[i].total += nodeScores[n.Name]
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].total != scores[j].total {
+			return scores[i].total > scores[j].total
+		}
+		return scores[i].name < scores[j].name
+	})
+
+	return scores, nil
+}
+
+// scoreConclusion tells where the pod would land, or for a pod already placed
+// how its node ranks, with the plugins it is outscored on.
+func scoreConclusion(pod *v1.Pod, assignedNodeName string, scorePlugins []config.Plugin, scores []*nodeScore) (string, []string) {
+	best := scores[0]
+	if len(assignedNodeName) == 0 {
+		return fmt.Sprintf("Pod %s would be scheduled to node %s with score %d", pod.Name, best.name, best.total), nil
+	}
+
+	for i, score := range scores {
+		if score.name != assignedNodeName {
+			continue
+		}
+		if i == 0 {
+			return fmt.Sprintf("Pod %s is on node %s which is ranked first with score %d", pod.Name, assignedNodeName, score.total), nil
+		}
+		return fmt.Sprintf("Pod %s is on node %s ranked %d with score %d, node %s ranks first with score %d", pod.Name, assignedNodeName, i+1, score.total, best.name, best.total),
+			scoreDifferences(scorePlugins, best, score)
+	}
+	return fmt.Sprintf("Pod %s is on node %s which is not feasible anymore, node %s ranks first with score %d", pod.Name, assignedNodeName, best.name, best.total), nil
+}
+
+func formatScoreReport(report *ScoreReport) string {
+	if report.Verdict == VerdictUnschedulable {
+		return fmt.Sprintf("[Fail] %s", report.Message)
+	}
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+	header := []string{"RANK", "NODE", "TOTAL"}
+	for _, pl := range report.ScorePlugins {
+		header = append(header, fmt.Sprintf("%s(x%d)", pl.Name, pl.Weight))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, score := range report.Nodes {
+		name := score.Name
+		if name == report.AssignedNode {
+			name += "*"
+		}
+		row := []string{fmt.Sprint(score.Rank), name, fmt.Sprint(score.Total)}
+		for _, pl := range report.ScorePlugins {
+			row = append(row, fmt.Sprint(score.Plugins[pl.Name]))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	fmt.Fprintf(&sb, "%d/%d nodes are feasible, scores are weighted and normalized\n", len(report.Nodes), report.NumAllNodes)
+	if len(report.AssignedNode) == 0 {
+		fmt.Fprintf(&sb, "[Success] %s", report.Message)
+	} else {
+		sb.WriteString(report.Message)
+	}
+	if len(report.Differences) > 0 {
+		fmt.Fprintf(&sb, "\nDifferences: %s", strings.Join(report.Differences, ", "))
+		sb.WriteString("\nThe cluster may have changed since the pod was scheduled, or the pod was placed by another scheduler or explicitly")
+	}
+	if preferred := formatPreferredNodeAffinity(report.pod, report.rankedNodes); len(preferred) != 0 {
+		sb.WriteString("\n" + preferred)
+	}
+	return sb.String()
+}
+
+// scoreDifferences lists the plugins on which the winner outscores the other node.
+func scoreDifferences(scorePlugins []config.Plugin, winner, other *nodeScore) []string {
+	diffs := make([]string, 0)
+	for _, pl := range scorePlugins {
+		diff := winner.pluginsScores[pl.Name] - other.pluginsScores[pl.Name]
+		if diff != 0 {
+			diffs = append(diffs, fmt.Sprintf("%s %+d", pl.Name, diff))
+		}
+	}
+	return diffs
+}
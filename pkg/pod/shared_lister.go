@@ -1,70 +1,92 @@
 package pod
 
 import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
 )
 
+// TroubleShootPodScheduleSnapshotSharedLister is a snapshot of every node of the
+// cluster with the pods assigned to it, the same as the snapshot kube-scheduler
+// takes from its cache at the beginning of each scheduling cycle.
 type TroubleShootPodScheduleSnapshotSharedLister struct {
-	TroubleShootPodScheduleSingleNodeInfoLister
+	TroubleShootPodScheduleNodeInfoLister
 }
 
-func NewTroubleShootPodScheduleSnapshotSharedLister(nodeInfo *framework.NodeInfo) framework.SharedLister {
-	return &TroubleShootPodScheduleSnapshotSharedLister{
-		TroubleShootPodScheduleSingleNodeInfoLister{
-			nodeInfo: nodeInfo,
-		},
+func NewTroubleShootPodScheduleSnapshotSharedLister(pods []*v1.Pod, nodes []*v1.Node) *TroubleShootPodScheduleSnapshotSharedLister {
+	l := &TroubleShootPodScheduleSnapshotSharedLister{}
+	l.Update(pods, nodes)
+	return l
+}
+
+// Update replaces the content of the snapshot, so frameworks holding it see the new state.
+func (l *TroubleShootPodScheduleSnapshotSharedLister) Update(pods []*v1.Pod, nodes []*v1.Node) {
+	podsByNode := make(map[string][]*v1.Pod)
+	for _, p := range pods {
+		if len(p.Spec.NodeName) == 0 {
+			continue
+		}
+		podsByNode[p.Spec.NodeName] = append(podsByNode[p.Spec.NodeName], p)
 	}
+
+	nodeInfoMap := make(map[string]*framework.NodeInfo, len(nodes))
+	nodeInfoList := make([]*framework.NodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		ni := framework.NewNodeInfo(podsByNode[node.Name]...)
+		ni.SetNode(node)
+		nodeInfoMap[node.Name] = ni
+		nodeInfoList = append(nodeInfoList, ni)
+	}
+	sort.Slice(nodeInfoList, func(i, j int) bool {
+		return nodeInfoList[i].Node().Name < nodeInfoList[j].Node().Name
+	})
+
+	l.nodeInfoMap = nodeInfoMap
+	l.nodeInfoList = nodeInfoList
 }
 
 func (l *TroubleShootPodScheduleSnapshotSharedLister) NodeInfos() framework.NodeInfoLister {
-	return &l.TroubleShootPodScheduleSingleNodeInfoLister
+	return &l.TroubleShootPodScheduleNodeInfoLister
 }
 
-type TroubleShootPodScheduleSingleNodeInfoLister struct {
-	nodeInfo *framework.NodeInfo
+type TroubleShootPodScheduleNodeInfoLister struct {
+	nodeInfoMap map[string]*framework.NodeInfo
+	// nodeInfoList is sorted by node name.
+	nodeInfoList []*framework.NodeInfo
 }
 
-func (l *TroubleShootPodScheduleSingleNodeInfoLister) List() ([]*framework.NodeInfo, error) {
-	return []*framework.NodeInfo{l.nodeInfo}, nil
+func (l *TroubleShootPodScheduleNodeInfoLister) List() ([]*framework.NodeInfo, error) {
+	return l.nodeInfoList, nil
 }
 
-func (l *TroubleShootPodScheduleSingleNodeInfoLister) HavePodsWithAffinityList() ([]*framework.NodeInfo, error) {
-	if len(l.nodeInfo.Pods) == 0 {
-		return nil, nil
-	}
-
-	for _, pod := range l.nodeInfo.Pods {
-		if len(pod.PreferredAffinityTerms) > 0 || len(pod.RequiredAffinityTerms) > 0 ||
-			len(pod.PreferredAntiAffinityTerms) > 0 || len(pod.RequiredAntiAffinityTerms) > 0 {
-			return []*framework.NodeInfo{l.nodeInfo}, nil
+func (l *TroubleShootPodScheduleNodeInfoLister) HavePodsWithAffinityList() ([]*framework.NodeInfo, error) {
+	result := make([]*framework.NodeInfo, 0)
+	for _, nodeInfo := range l.nodeInfoList {
+		if len(nodeInfo.PodsWithAffinity) > 0 {
+			result = append(result, nodeInfo)
 		}
 	}
 
-	return nil, nil
+	return result, nil
 }
 
-func (l *TroubleShootPodScheduleSingleNodeInfoLister) HavePodsWithRequiredAntiAffinityList() ([]*framework.NodeInfo, error) {
-	if len(l.nodeInfo.Pods) == 0 {
-		return nil, nil
-	}
-
-	for _, pod := range l.nodeInfo.Pods {
-		if len(pod.RequiredAntiAffinityTerms) > 0 {
-			return []*framework.NodeInfo{l.nodeInfo}, nil
+func (l *TroubleShootPodScheduleNodeInfoLister) HavePodsWithRequiredAntiAffinityList() ([]*framework.NodeInfo, error) {
+	result := make([]*framework.NodeInfo, 0)
+	for _, nodeInfo := range l.nodeInfoList {
+		if len(nodeInfo.PodsWithRequiredAntiAffinity) > 0 {
+			result = append(result, nodeInfo)
 		}
 	}
 
-	return nil, nil
+	return result, nil
 }
 
-func (l *TroubleShootPodScheduleSingleNodeInfoLister) Get(nodeName string) (*framework.NodeInfo, error) {
-	if l.nodeInfo.Node() == nil {
-		return nil, nil
+func (l *TroubleShootPodScheduleNodeInfoLister) Get(nodeName string) (*framework.NodeInfo, error) {
+	nodeInfo, ok := l.nodeInfoMap[nodeName]
+	if !ok || nodeInfo.Node() == nil {
+		return nil, fmt.Errorf("nodeinfo not found for node name %q", nodeName)
 	}
 
-	if l.nodeInfo.Node().Name == nodeName {
-		return l.nodeInfo, nil
-	} else {
-		return nil, nil
-	}
+	return nodeInfo, nil
 }
@@ -0,0 +1,343 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/nodeaffinity"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/nodeports"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/nodeunschedulable"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/tainttoleration"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+// maxSuggestionsPerPlugin bounds the suggestions of a plugin, the ones covering
+// the most nodes come first.
+const maxSuggestionsPerPlugin = 3
+
+// Suggestion is a remediation of the failure of a plugin on some nodes.
+type Suggestion struct {
+	Plugin  string   `json:"plugin"`
+	Nodes   []string `json:"nodes"`
+	Message string   `json:"message"`
+	// Snippet is a manifest fragment or command applying the suggestion.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// suggester suggests remediations for the failure of a plugin on a node, the
+// reasons are the ones of the plugin status.
+type suggester func(pod *v1.Pod, nodeInfo *framework.NodeInfo, reasons []string) []Suggestion
+
+var suggesters = map[string]suggester{
+	tainttoleration.Name:   suggestTaintToleration,
+	noderesources.FitName:  suggestNodeResourcesFit,
+	nodeaffinity.Name:      suggestNodeAffinity,
+	nodeunschedulable.Name: suggestNodeUnschedulable,
+	nodeports.Name:         suggestNodePorts,
+}
+
+// suggestRemediations runs the suggester of every plugin failing on the nodes, and
+// merges the same suggestions made for several nodes.
+func suggestRemediations(pod *v1.Pod, nodes []NodeResult, nodeInfos []*framework.NodeInfo) []Suggestion {
+	nodeInfoMap := newNodeInfoMap(nodeInfos)
+	merged := make([]*Suggestion, 0)
+	index := make(map[string]*Suggestion)
+	for _, n := range nodes {
+		nodeInfo, ok := nodeInfoMap[n.Name]
+		if n.Fit || !ok {
+			continue
+		}
+		for _, pl := range n.Plugins {
+			suggest, ok := suggesters[pl.Plugin]
+			if !ok {
+				continue
+			}
+			for _, suggestion := range suggest(pod, nodeInfo, pl.Reasons) {
+				suggestion := suggestion
+				key := strings.Join([]string{suggestion.Plugin, suggestion.Message, suggestion.Snippet}, "\x00")
+				if existing, ok := index[key]; ok {
+					existing.Nodes = append(existing.Nodes, n.Name)
+					continue
+				}
+				suggestion.Nodes = []string{n.Name}
+				index[key] = &suggestion
+				merged = append(merged, &suggestion)
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Plugin != merged[j].Plugin {
+			return merged[i].Plugin < merged[j].Plugin
+		}
+		return len(merged[i].Nodes) > len(merged[j].Nodes)
+	})
+	result := make([]Suggestion, 0, len(merged))
+	perPlugin := make(map[string]int)
+	for _, suggestion := range merged {
+		if perPlugin[suggestion.Plugin] == maxSuggestionsPerPlugin {
+			continue
+		}
+		perPlugin[suggestion.Plugin]++
+		result = append(result, *suggestion)
+	}
+	return result
+}
+
+func suggestTaintToleration(pod *v1.Pod, nodeInfo *framework.NodeInfo, _ []string) []Suggestion {
+	taints := untoleratedTaints(nodeInfo.Node().Spec.Taints, pod.Spec.Tolerations)
+	if len(taints) == 0 {
+		return nil
+	}
+	snippet, err := yaml.Marshal(map[string][]v1.Toleration{"tolerations": tolerationsFor(taints)})
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(taints))
+	for _, t := range taints {
+		names = append(names, t.ToString())
+	}
+	return []Suggestion{{
+		Plugin:  tainttoleration.Name,
+		Message: fmt.Sprintf("Add tolerations for the taints %s to the pod, or remove them with `kubectl taint nodes <node> %s-`", strings.Join(names, ","), strings.Join(names, "- ")),
+		Snippet: strings.TrimSuffix(string(snippet), "\n"),
+	}}
+}
+
+// untoleratedTaints returns the NoSchedule and NoExecute taints not tolerated by
+// the tolerations, the ones the TaintToleration filter rejects the node for.
+func untoleratedTaints(taints []v1.Taint, tolerations []v1.Toleration) []v1.Taint {
+	result := make([]v1.Taint, 0)
+	for i := range taints {
+		t := &taints[i]
+		if t.Effect != v1.TaintEffectNoSchedule && t.Effect != v1.TaintEffectNoExecute {
+			continue
+		}
+		if !corev1helpers.TolerationsTolerateTaint(tolerations, t) {
+			result = append(result, *t)
+		}
+	}
+	return result
+}
+
+// tolerationsFor returns the tolerations matching exactly the taints.
+func tolerationsFor(taints []v1.Taint) []v1.Toleration {
+	tolerations := make([]v1.Toleration, 0, len(taints))
+	for _, t := range taints {
+		toleration := v1.Toleration{Key: t.Key, Operator: v1.TolerationOpExists, Effect: t.Effect}
+		if len(t.Value) != 0 {
+			toleration.Operator = v1.TolerationOpEqual
+			toleration.Value = t.Value
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	return tolerations
+}
+
+func suggestNodeResourcesFit(pod *v1.Pod, nodeInfo *framework.NodeInfo, _ []string) []Suggestion {
+	insufficient := noderesources.Fits(pod, nodeInfo, true)
+	if len(insufficient) == 0 {
+		return nil
+	}
+
+	suggestions := make([]Suggestion, 0)
+	shortfalls := make(map[v1.ResourceName]int64, len(insufficient))
+	for _, r := range insufficient {
+		shortfall := r.Requested + r.Used - r.Capacity
+		shortfalls[r.ResourceName] = shortfall
+		switch {
+		case r.ResourceName == v1.ResourcePods:
+		case r.Requested > r.Capacity:
+			suggestions = append(suggestions, Suggestion{
+				Plugin: noderesources.FitName,
+				Message: fmt.Sprintf("The pod requests %s %s, more than the %s allocatable of the node, reduce the request to at most %s",
+					formatResourceValue(r.ResourceName, r.Requested), r.ResourceName, formatResourceValue(r.ResourceName, r.Capacity), formatResourceValue(r.ResourceName, r.Capacity)),
+			})
+		case shortfall < r.Requested:
+			suggestions = append(suggestions, Suggestion{
+				Plugin: noderesources.FitName,
+				Message: fmt.Sprintf("Reduce the %s request of the pod by %s, from %s to %s",
+					r.ResourceName, formatResourceValue(r.ResourceName, shortfall), formatResourceValue(r.ResourceName, r.Requested), formatResourceValue(r.ResourceName, r.Requested-shortfall)),
+			})
+		}
+	}
+
+	if victims, ok := podsToEvict(nodeInfo, shortfalls); ok {
+		freed := make([]string, 0, len(insufficient))
+		for _, r := range insufficient {
+			freed = append(freed, fmt.Sprintf("%s %s", formatResourceValue(r.ResourceName, shortfalls[r.ResourceName]), r.ResourceName))
+		}
+		suggestions = append(suggestions, Suggestion{
+			Plugin:  noderesources.FitName,
+			Message: fmt.Sprintf("Evict %s to free %s", formatPrioritizedPods(newPrioritizedPodReferences(victims)), strings.Join(freed, ",")),
+		})
+	}
+	return suggestions
+}
+
+// podsToEvict picks the pods of the node to evict, the lowest priority and the
+// largest requests first, until the shortfalls are covered. Pods of DaemonSets and
+// static pods come back right after the eviction, so they are never picked.
+func podsToEvict(nodeInfo *framework.NodeInfo, shortfalls map[v1.ResourceName]int64) ([]*v1.Pod, bool) {
+	candidates := make([]*v1.Pod, 0, len(nodeInfo.Pods))
+	requests := make(map[*v1.Pod]*framework.Resource, len(nodeInfo.Pods))
+	for _, pi := range nodeInfo.Pods {
+		if pi.Pod.DeletionTimestamp != nil || isDaemonSetPod(pi.Pod) || isStaticPod(pi.Pod) {
+			continue
+		}
+		candidates = append(candidates, pi.Pod)
+		requests[pi.Pod] = podResourceRequest(pi.Pod)
+	}
+
+	// Sort by the largest share of a shortfall the pod frees.
+	share := func(p *v1.Pod) float64 {
+		max := 0.0
+		for name, shortfall := range shortfalls {
+			if s := float64(resourceValue(requests[p], name)) / float64(shortfall); s > max {
+				max = s
+			}
+		}
+		return max
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, pj := corev1helpers.PodPriority(candidates[i]), corev1helpers.PodPriority(candidates[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return share(candidates[i]) > share(candidates[j])
+	})
+
+	remaining := make(map[v1.ResourceName]int64, len(shortfalls))
+	for name, shortfall := range shortfalls {
+		remaining[name] = shortfall
+	}
+	victims := make([]*v1.Pod, 0)
+	for _, p := range candidates {
+		covered := true
+		for _, shortfall := range remaining {
+			covered = covered && shortfall <= 0
+		}
+		if covered {
+			break
+		}
+		frees := false
+		for name, shortfall := range remaining {
+			if shortfall > 0 && resourceValue(requests[p], name) > 0 {
+				frees = true
+			}
+		}
+		if !frees {
+			continue
+		}
+		victims = append(victims, p)
+		for name := range remaining {
+			remaining[name] -= resourceValue(requests[p], name)
+		}
+	}
+	for _, shortfall := range remaining {
+		if shortfall > 0 {
+			return nil, false
+		}
+	}
+	return victims, true
+}
+
+func isDaemonSetPod(pod *v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller && ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+func isStaticPod(pod *v1.Pod) bool {
+	_, ok := pod.Annotations[v1.MirrorPodAnnotationKey]
+	return ok
+}
+
+func suggestNodeAffinity(pod *v1.Pod, nodeInfo *framework.NodeInfo, _ []string) []Suggestion {
+	node := nodeInfo.Node()
+	// The addedAffinity of the profile is left out, only the pod can be relaxed.
+	mismatches := nodeAffinityMismatches(diffNodeAffinity(pod, nil, node), node)
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	nodeLabels := make([]string, 0, len(node.Labels))
+	for key, value := range node.Labels {
+		nodeLabels = append(nodeLabels, key+"="+value)
+	}
+	sort.Strings(nodeLabels)
+	return []Suggestion{{
+		Plugin:  nodeaffinity.Name,
+		Message: "Relax the node selector or the required node affinity of the pod, or label the node to match them",
+		Snippet: fmt.Sprintf("%s\nnode labels: %s", strings.Join(mismatches, "\n"), strings.Join(nodeLabels, ",")),
+	}}
+}
+
+func formatNodeSelectorRequirement(r v1.NodeSelectorRequirement) string {
+	if len(r.Values) == 0 {
+		return fmt.Sprintf("%s %s", r.Key, r.Operator)
+	}
+	return fmt.Sprintf("%s %s [%s]", r.Key, r.Operator, strings.Join(r.Values, ","))
+}
+
+// formatNodeLabel formats the label of the node, or the name of the node for
+// the metadata.name field.
+func formatNodeLabel(node *v1.Node, key string) string {
+	if key == "metadata.name" {
+		return fmt.Sprintf("%s=%s", key, node.Name)
+	}
+	if value, ok := node.Labels[key]; ok {
+		return fmt.Sprintf("%s=%s", key, value)
+	}
+	return fmt.Sprintf("no %s label", key)
+}
+
+func suggestNodeUnschedulable(_ *v1.Pod, _ *framework.NodeInfo, _ []string) []Suggestion {
+	return []Suggestion{{
+		Plugin:  nodeunschedulable.Name,
+		Message: "The node is cordoned, uncordon it with `kubectl uncordon <node>` or tolerate the node.kubernetes.io/unschedulable:NoSchedule taint",
+	}}
+}
+
+func suggestNodePorts(pod *v1.Pod, nodeInfo *framework.NodeInfo, _ []string) []Suggestion {
+	conflicts := make([]string, 0)
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort <= 0 {
+				continue
+			}
+			protocol := string(p.Protocol)
+			if len(protocol) == 0 {
+				protocol = string(v1.ProtocolTCP)
+			}
+			if nodeInfo.UsedPorts.CheckConflict(p.HostIP, protocol, p.HostPort) {
+				conflicts = append(conflicts, fmt.Sprintf("%d/%s", p.HostPort, protocol))
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return []Suggestion{{
+		Plugin:  nodeports.Name,
+		Message: fmt.Sprintf("Host ports %s are in use on the node, use other host ports or a Service instead", strings.Join(conflicts, ",")),
+	}}
+}
+
+func formatSuggestions(suggestions []Suggestion) string {
+	lines := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		lines = append(lines, fmt.Sprintf("[Suggestion] %s on node(s) %s: %s", s.Plugin, strings.Join(s.Nodes, ","), s.Message))
+		if len(s.Snippet) != 0 {
+			lines = append(lines, "    "+strings.ReplaceAll(s.Snippet, "\n", "\n    "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
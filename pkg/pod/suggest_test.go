@@ -0,0 +1,96 @@
+package pod
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+	"reflect"
+	"testing"
+)
+
+func TestPodsToEvict(t *testing.T) {
+	cpu := func(q string) map[v1.ResourceName]string {
+		return map[v1.ResourceName]string{v1.ResourceCPU: q}
+	}
+	static := newTestPod("static").Priority(0).Req(cpu("2")).Obj()
+	static.Annotations = map[string]string{v1.MirrorPodAnnotationKey: "x"}
+
+	tests := []struct {
+		name       string
+		pods       []*v1.Pod
+		shortfalls map[v1.ResourceName]int64
+		want       []string
+		wantOK     bool
+	}{
+		{
+			name: "lowest priority first",
+			pods: []*v1.Pod{
+				newTestPod("high").Priority(100).Req(cpu("1")).Obj(),
+				newTestPod("low").Priority(0).Req(cpu("500m")).Obj(),
+			},
+			shortfalls: map[v1.ResourceName]int64{v1.ResourceCPU: 500},
+			want:       []string{"low"},
+			wantOK:     true,
+		},
+		{
+			name: "largest request first within a priority",
+			pods: []*v1.Pod{
+				newTestPod("small").Priority(0).Req(cpu("200m")).Obj(),
+				newTestPod("large").Priority(0).Req(cpu("800m")).Obj(),
+				newTestPod("medium").Priority(0).Req(cpu("400m")).Obj(),
+			},
+			shortfalls: map[v1.ResourceName]int64{v1.ResourceCPU: 1000},
+			want:       []string{"large", "medium"},
+			wantOK:     true,
+		},
+		{
+			name: "pods not freeing a short resource are skipped",
+			pods: []*v1.Pod{
+				newTestPod("memory").Priority(0).Req(map[v1.ResourceName]string{v1.ResourceMemory: "1Gi"}).Obj(),
+				newTestPod("cpu").Priority(10).Req(cpu("1")).Obj(),
+			},
+			shortfalls: map[v1.ResourceName]int64{v1.ResourceCPU: 1000},
+			want:       []string{"cpu"},
+			wantOK:     true,
+		},
+		{
+			name: "DaemonSet, static and terminating pods are never picked",
+			pods: []*v1.Pod{
+				newTestPod("daemon").Priority(0).Req(cpu("2")).OwnerReference("ds", appsv1.SchemeGroupVersion.WithKind("DaemonSet")).Obj(),
+				static,
+				newTestPod("terminating").Priority(0).Req(cpu("2")).Terminating().Obj(),
+				newTestPod("web").Priority(0).Req(cpu("1")).Obj(),
+			},
+			shortfalls: map[v1.ResourceName]int64{v1.ResourceCPU: 1500},
+			wantOK:     false,
+		},
+		{
+			name: "every short resource is covered",
+			pods: []*v1.Pod{
+				newTestPod("cpu").Priority(0).Req(cpu("1")).Obj(),
+				newTestPod("memory").Priority(0).Req(map[v1.ResourceName]string{v1.ResourceMemory: "1Gi"}).Obj(),
+			},
+			shortfalls: map[v1.ResourceName]int64{v1.ResourceCPU: 1000, v1.ResourceMemory: 1 << 30},
+			want:       []string{"cpu", "memory"},
+			wantOK:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victims, ok := podsToEvict(newTestNodeInfo(st.MakeNode().Name("n1").Obj(), tt.pods...), tt.shortfalls)
+			if ok != tt.wantOK {
+				t.Fatalf("want ok %v, got %v", tt.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			got := make([]string, 0, len(victims))
+			for _, p := range victims {
+				got = append(got, p.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"github.com/briandowns/spinner"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	frameworkplugins "k8s.io/kubernetes/pkg/scheduler/framework/plugins"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/tainttoleration"
+	"os"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"troubleshooter/pkg"
+)
+
+// TolerationReport matches the distinct taints of the nodes against the
+// tolerations of the pod, and tells the tolerations to add to run on a node pool.
+type TolerationReport struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Pod        PodReference `json:"pod"`
+	Profile    string       `json:"profile"`
+	Verdict    Verdict      `json:"verdict"`
+	Message    string       `json:"message"`
+	// Tolerations are the tolerations of the pod, the columns of the matrix.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	Taints      []TaintMatch    `json:"taints,omitempty"`
+	// Pool is the label selector of the node pool, empty for every node.
+	Pool      string           `json:"pool,omitempty"`
+	PoolNodes []PoolNodeResult `json:"poolNodes"`
+	// MissingTolerations is the smallest list of tolerations to add to the pod
+	// for the TaintToleration plugin to accept every node of the pool.
+	MissingTolerations []v1.Toleration `json:"missingTolerations,omitempty"`
+}
+
+// TaintMatch is a distinct taint of the nodes, ToleratedBy are the indexes of the
+// tolerations of the pod tolerating it.
+type TaintMatch struct {
+	Key         string         `json:"key"`
+	Value       string         `json:"value,omitempty"`
+	Effect      v1.TaintEffect `json:"effect"`
+	Nodes       int            `json:"nodes"`
+	PoolNodes   int            `json:"poolNodes"`
+	ToleratedBy []int          `json:"toleratedBy,omitempty"`
+}
+
+// PoolNodeResult is the verdict of the TaintToleration filter on a node of the pool.
+type PoolNodeResult struct {
+	Name    string   `json:"name"`
+	Fit     bool     `json:"fit"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// ExecuteTolerations prints the taints of the nodes against the tolerations of
+// the pod, and the tolerations missing to run on the nodes matching the pool.
+func (s *ScheduleTroubleShooter) ExecuteTolerations(pool string) (Verdict, error) {
+	selector, err := labels.Parse(pool)
+	if err != nil {
+		return "", pkg.NewConfigError(fmt.Errorf("Invalid node pool %q: %v\n", pool, err))
+	}
+
+	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	sp.Start()
+
+	ctx := context.Background()
+	report, err := s.tolerationsCore(ctx, selector)
+	sp.Stop()
+	if err != nil {
+		return "", err
+	}
+	err = printReport(os.Stdout, s.outputFormat, report, func() string {
+		return formatTolerationReport(report)
+	})
+	if err != nil {
+		return "", err
+	}
+	return report.Verdict, nil
+}
+
+func (s *ScheduleTroubleShooter) tolerationsCore(ctx context.Context, pool labels.Selector) (*TolerationReport, error) {
+	report := &TolerationReport{
+		APIVersion:  ScheduleReportAPIVersion,
+		Kind:        "TolerationReport",
+		Pod:         newPodReference(s.pod),
+		Profile:     s.framework.ProfileName(),
+		Tolerations: s.pod.Spec.Tolerations,
+		Pool:        pool.String(),
+		PoolNodes:   make([]PoolNodeResult, 0),
+	}
+
+	// The nodes of the pool are evaluated by the TaintToleration filter alone, the
+	// way RunFilterPlugins runs it, so the verdicts agree with the schedule command.
+	fw, err := NewFramework(
+		frameworkplugins.NewInTreeRegistry(),
+		&config.KubeSchedulerProfile{
+			SchedulerName: s.framework.ProfileName(),
+			Plugins: &config.Plugins{
+				Filter: config.PluginSet{Enabled: []config.Plugin{{Name: tainttoleration.Name}}},
+			},
+		},
+		WithSnapshotSharedLister(s.snapshot),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	taints := make(map[v1.Taint]*TaintMatch)
+	untolerated := make([]v1.Taint, 0)
+	for _, nodeInfo := range s.nodeInfos {
+		node := nodeInfo.Node()
+		inPool := pool.Matches(labels.Set(node.Labels))
+		for _, t := range node.Spec.Taints {
+			key := v1.Taint{Key: t.Key, Value: t.Value, Effect: t.Effect}
+			m, ok := taints[key]
+			if !ok {
+				m = newTaintMatch(&key, s.pod.Spec.Tolerations)
+				taints[key] = m
+			}
+			m.Nodes++
+			if inPool {
+				m.PoolNodes++
+			}
+		}
+		if !inPool {
+			continue
+		}
+
+		result := PoolNodeResult{Name: node.Name, Fit: true}
+		statuses := fw.RunFilterPlugins(ctx, framework.NewCycleState(), s.pod, nodeInfo)
+		if status, ok := statuses[tainttoleration.Name]; ok {
+			if status.Code() == framework.Error {
+				return nil, status.AsError()
+			}
+			result.Fit = false
+			result.Reasons = status.Reasons()
+			untolerated = append(untolerated, untoleratedTaints(node.Spec.Taints, s.pod.Spec.Tolerations)...)
+		}
+		report.PoolNodes = append(report.PoolNodes, result)
+	}
+
+	for _, m := range taints {
+		report.Taints = append(report.Taints, *m)
+	}
+	sort.Slice(report.Taints, func(i, j int) bool {
+		a, b := report.Taints[i], report.Taints[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+		return a.Effect < b.Effect
+	})
+	report.MissingTolerations = minimalTolerations(untolerated)
+
+	rejected := 0
+	for _, n := range report.PoolNodes {
+		if !n.Fit {
+			rejected++
+		}
+	}
+	switch {
+	case len(report.PoolNodes) == 0:
+		report.Verdict = VerdictUnschedulable
+		report.Message = fmt.Sprintf("No node matches the node pool %q", report.Pool)
+	case rejected == 0:
+		report.Verdict = VerdictSchedulable
+		report.Message = fmt.Sprintf("The pod tolerates the taints of all %d nodes of the pool", len(report.PoolNodes))
+	default:
+		report.Verdict = VerdictUnschedulable
+		report.Message = fmt.Sprintf("The taints of %d/%d nodes of the pool are not tolerated by the pod", rejected, len(report.PoolNodes))
+	}
+	if !s.filtersTaints() {
+		report.Message += fmt.Sprintf(", though profile %s doesn't enable the %s filter", report.Profile, tainttoleration.Name)
+	}
+	return report, nil
+}
+
+func newTaintMatch(taint *v1.Taint, tolerations []v1.Toleration) *TaintMatch {
+	m := &TaintMatch{Key: taint.Key, Value: taint.Value, Effect: taint.Effect}
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			m.ToleratedBy = append(m.ToleratedBy, i)
+		}
+	}
+	return m
+}
+
+// filtersTaints tells whether the selected profile runs the TaintToleration filter.
+func (s *ScheduleTroubleShooter) filtersTaints() bool {
+	for _, pl := range s.framework.ListPlugins().Filter.Enabled {
+		if pl.Name == tainttoleration.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// minimalTolerations returns one toleration per key of the taints: it matches the
+// value when the taints of the key share it, or any value otherwise, and the effect
+// when the taints of the key share it, or any effect otherwise.
+func minimalTolerations(taints []v1.Taint) []v1.Toleration {
+	values := make(map[string]map[string]bool)
+	effects := make(map[string]map[v1.TaintEffect]bool)
+	keys := make([]string, 0)
+	for _, t := range taints {
+		if _, ok := values[t.Key]; !ok {
+			values[t.Key] = make(map[string]bool)
+			effects[t.Key] = make(map[v1.TaintEffect]bool)
+			keys = append(keys, t.Key)
+		}
+		values[t.Key][t.Value] = true
+		effects[t.Key][t.Effect] = true
+	}
+	sort.Strings(keys)
+
+	tolerations := make([]v1.Toleration, 0, len(keys))
+	for _, key := range keys {
+		toleration := v1.Toleration{Key: key, Operator: v1.TolerationOpExists}
+		if len(values[key]) == 1 {
+			for value := range values[key] {
+				if len(value) != 0 {
+					toleration.Operator = v1.TolerationOpEqual
+					toleration.Value = value
+				}
+			}
+		}
+		if len(effects[key]) == 1 {
+			for effect := range effects[key] {
+				toleration.Effect = effect
+			}
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	return tolerations
+}
+
+func formatTolerationReport(report *TolerationReport) string {
+	var sb strings.Builder
+	if report.Verdict == VerdictSchedulable {
+		fmt.Fprintf(&sb, "[Pass] %s\n", report.Message)
+	} else {
+		fmt.Fprintf(&sb, "[Fail] %s\n", report.Message)
+	}
+
+	if len(report.Taints) == 0 {
+		sb.WriteString("No node is tainted\n")
+	} else {
+		for i, t := range report.Tolerations {
+			fmt.Fprintf(&sb, "Toleration #%d: %s\n", i+1, formatToleration(&t))
+		}
+		w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+		header := []string{"KEY", "VALUE", "EFFECT", "NODES", "POOL NODES"}
+		for i := range report.Tolerations {
+			header = append(header, fmt.Sprintf("#%d", i+1))
+		}
+		header = append(header, "TOLERATED")
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, t := range report.Taints {
+			value := t.Value
+			if len(value) == 0 {
+				value = "-"
+			}
+			row := []string{t.Key, value, string(t.Effect), fmt.Sprint(t.Nodes), fmt.Sprint(t.PoolNodes)}
+			tolerated := make(map[int]bool, len(t.ToleratedBy))
+			for _, i := range t.ToleratedBy {
+				tolerated[i] = true
+			}
+			for i := range report.Tolerations {
+				if tolerated[i] {
+					row = append(row, "yes")
+				} else {
+					row = append(row, "-")
+				}
+			}
+			switch {
+			case len(t.ToleratedBy) != 0:
+				row = append(row, "yes")
+			case t.Effect == v1.TaintEffectPreferNoSchedule:
+				row = append(row, "no (lowers the score only)")
+			default:
+				row = append(row, "no")
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		w.Flush()
+	}
+
+	for _, n := range report.PoolNodes {
+		if !n.Fit {
+			fmt.Fprintf(&sb, "Node %s: %s\n", n.Name, strings.Join(n.Reasons, ", "))
+		}
+	}
+	if len(report.MissingTolerations) > 0 {
+		snippet, err := yaml.Marshal(map[string][]v1.Toleration{"tolerations": report.MissingTolerations})
+		if err == nil {
+			sb.WriteString("Add the tolerations to the pod to run on the pool:\n")
+			for _, line := range strings.Split(strings.TrimSuffix(string(snippet), "\n"), "\n") {
+				fmt.Fprintf(&sb, "    %s\n", line)
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func formatToleration(t *v1.Toleration) string {
+	var sb strings.Builder
+	key := t.Key
+	if len(key) == 0 {
+		key = "<any key>"
+	}
+	operator := t.Operator
+	if len(operator) == 0 {
+		operator = v1.TolerationOpEqual
+	}
+	fmt.Fprintf(&sb, "%s %s", key, operator)
+	if operator == v1.TolerationOpEqual {
+		fmt.Fprintf(&sb, " %q", t.Value)
+	}
+	effect := string(t.Effect)
+	if len(effect) == 0 {
+		effect = "<any effect>"
+	}
+	fmt.Fprintf(&sb, ", %s", effect)
+	if t.TolerationSeconds != nil {
+		fmt.Fprintf(&sb, " for %ds", *t.TolerationSeconds)
+	}
+	return sb.String()
+}
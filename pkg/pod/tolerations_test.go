@@ -0,0 +1,89 @@
+package pod
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestMinimalTolerations(t *testing.T) {
+	tests := []struct {
+		name   string
+		taints []v1.Taint
+		want   []v1.Toleration
+	}{
+		{
+			name: "no taint",
+			want: []v1.Toleration{},
+		},
+		{
+			name: "shared value and effect",
+			taints: []v1.Taint{
+				{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule},
+			},
+			want: []v1.Toleration{
+				{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "gpu", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name: "different values",
+			taints: []v1.Taint{
+				{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "fpga", Effect: v1.TaintEffectNoSchedule},
+			},
+			want: []v1.Toleration{
+				{Key: "dedicated", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name: "different effects",
+			taints: []v1.Taint{
+				{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoExecute},
+			},
+			want: []v1.Toleration{
+				{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "gpu"},
+			},
+		},
+		{
+			name: "empty value",
+			taints: []v1.Taint{
+				{Key: "spot", Effect: v1.TaintEffectNoSchedule},
+			},
+			want: []v1.Toleration{
+				{Key: "spot", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name: "one toleration per key, sorted",
+			taints: []v1.Taint{
+				{Key: "zeta", Value: "z", Effect: v1.TaintEffectNoExecute},
+				{Key: "alpha", Value: "a", Effect: v1.TaintEffectNoSchedule},
+				{Key: "zeta", Value: "y", Effect: v1.TaintEffectNoExecute},
+			},
+			want: []v1.Toleration{
+				{Key: "alpha", Operator: v1.TolerationOpEqual, Value: "a", Effect: v1.TaintEffectNoSchedule},
+				{Key: "zeta", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := minimalTolerations(tt.taints)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, got)
+			}
+			// Every taint must be tolerated by the generated tolerations.
+			for i := range tt.taints {
+				tolerated := false
+				for _, toleration := range got {
+					tolerated = tolerated || toleration.ToleratesTaint(&tt.taints[i])
+				}
+				if !tolerated {
+					t.Errorf("taint %v is not tolerated", tt.taints[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/helper"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/podtopologyspread"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// TopologySpreadExplanation explains a topology spread constraint of the pod with
+// the matching pods counted per domain like the PodTopologySpread plugin does.
+type TopologySpreadExplanation struct {
+	TopologyKey       string                           `json:"topologyKey"`
+	MaxSkew           int32                            `json:"maxSkew"`
+	WhenUnsatisfiable v1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable"`
+	LabelSelector     string                           `json:"labelSelector"`
+	Domains           []TopologyDomain                 `json:"domains,omitempty"`
+	// MinMatchingPods is the lowest count of the domains.
+	MinMatchingPods int32 `json:"minMatchingPods"`
+	// SelfMatch tells whether the pod matches the selector and counts in the skew.
+	SelfMatch bool `json:"selfMatch"`
+	// Default tells the constraint is a default constraint of the profile, applied
+	// because the pod has none.
+	Default bool `json:"default,omitempty"`
+	// ScoringOnly tells the constraint is ScheduleAnyway, which only lowers the
+	// score of the nodes and never rejects one, so no skew is computed.
+	ScoringOnly bool                 `json:"scoringOnly,omitempty"`
+	Nodes       []TopologySpreadNode `json:"nodes,omitempty"`
+	// MissingLabelNodes are the nodes lacking a topology key of the DoNotSchedule
+	// constraints, which are neither counted nor feasible.
+	MissingLabelNodes []string `json:"missingLabelNodes,omitempty"`
+}
+
+// TopologyDomain is a value of the topology key with the pods matching the
+// selector on the nodes of the domain.
+type TopologyDomain struct {
+	Value        string `json:"value"`
+	MatchingPods int32  `json:"matchingPods"`
+}
+
+// systemDefaultTopologySpreadConstraints are the constraints the PodTopologySpread
+// plugin applies with the System defaulting type.
+var systemDefaultTopologySpreadConstraints = []v1.TopologySpreadConstraint{
+	{
+		TopologyKey:       v1.LabelHostname,
+		WhenUnsatisfiable: v1.ScheduleAnyway,
+		MaxSkew:           3,
+	},
+	{
+		TopologyKey:       v1.LabelTopologyZone,
+		WhenUnsatisfiable: v1.ScheduleAnyway,
+		MaxSkew:           5,
+	},
+}
+
+// TopologySpreadNode is the skew placing the pod on the node would create.
+type TopologySpreadNode struct {
+	Node   string `json:"node"`
+	Domain string `json:"domain"`
+	Skew   int32  `json:"skew"`
+}
+
+// explainTopologySpread explains the topology spread constraints of the pod, or
+// the default ones of the profile, when the PodTopologySpread plugin rejects some
+// nodes, with the skews of those nodes. The matching pods are counted across every
+// node of the snapshot, not only the evaluated ones.
+func (s *ScheduleTroubleShooter) explainTopologySpread(report *ScheduleReport) ([]TopologySpreadExplanation, error) {
+	failedNodes := make([]string, 0)
+	for _, n := range report.Nodes {
+		if failsPlugin(n, podtopologyspread.Name) {
+			failedNodes = append(failedNodes, n.Name)
+		}
+	}
+	if len(failedNodes) == 0 {
+		return nil, nil
+	}
+	constraints, err := s.topologySpreadConstraints()
+	if err != nil || len(constraints) == 0 {
+		return nil, err
+	}
+
+	allNodes, err := s.snapshot.NodeInfos().List()
+	if err != nil {
+		return nil, err
+	}
+	// Like the plugin, only the nodes passing the node affinity and the node
+	// selector of the pod are counted. Ignore parsing errors like it does.
+	requiredSchedulingTerm := nodeaffinity.GetRequiredNodeAffinity(s.pod)
+	eligibleNodes := make([]*framework.NodeInfo, 0, len(allNodes))
+	for _, n := range allNodes {
+		if match, _ := requiredSchedulingTerm.Match(n.Node()); match {
+			eligibleNodes = append(eligibleNodes, n)
+		}
+	}
+
+	explanations := make([]TopologySpreadExplanation, 0, len(constraints))
+	for _, c := range constraints {
+		explanation, err := s.explainTopologySpreadConstraint(c, constraints, eligibleNodes, failedNodes)
+		if err != nil {
+			return nil, err
+		}
+		explanation.Default = len(s.pod.Spec.TopologySpreadConstraints) == 0
+		explanations = append(explanations, *explanation)
+	}
+	return explanations, nil
+}
+
+// topologySpreadConstraints returns the constraints of the pod. A pod without any
+// gets the default constraints of the profile, selecting the pods of the Services,
+// ReplicationControllers, ReplicaSets and StatefulSets selecting it like the plugin
+// does, none when nothing selects it.
+func (s *ScheduleTroubleShooter) topologySpreadConstraints() ([]v1.TopologySpreadConstraint, error) {
+	if len(s.pod.Spec.TopologySpreadConstraints) != 0 {
+		return s.pod.Spec.TopologySpreadConstraints, nil
+	}
+	defaults, err := defaultTopologySpreadConstraints(s.profile)
+	if err != nil || len(defaults) == 0 {
+		return nil, err
+	}
+
+	// The plugin registers these listers when it has default constraints.
+	factory := s.informerFactory
+	selector := helper.DefaultSelector(s.pod,
+		factory.Core().V1().Services().Lister(),
+		factory.Core().V1().ReplicationControllers().Lister(),
+		factory.Apps().V1().ReplicaSets().Lister(),
+		factory.Apps().V1().StatefulSets().Lister(),
+	)
+	if selector.Empty() {
+		return nil, nil
+	}
+	labelSelector, err := metav1.ParseToLabelSelector(selector.String())
+	if err != nil {
+		return nil, err
+	}
+	constraints := make([]v1.TopologySpreadConstraint, 0, len(defaults))
+	for _, c := range defaults {
+		c.LabelSelector = labelSelector
+		constraints = append(constraints, c)
+	}
+	return constraints, nil
+}
+
+// defaultTopologySpreadConstraints returns the default constraints of the
+// PodTopologySpread args of the profile, the system ones unless listed.
+func defaultTopologySpreadConstraints(profile *config.KubeSchedulerProfile) ([]v1.TopologySpreadConstraint, error) {
+	for _, pc := range profile.PluginConfig {
+		if pc.Name != podtopologyspread.Name || pc.Args == nil {
+			continue
+		}
+		args, ok := pc.Args.(*config.PodTopologySpreadArgs)
+		if !ok {
+			return nil, fmt.Errorf("want args to be of type PodTopologySpreadArgs, got %T", pc.Args)
+		}
+		if args.DefaultingType == config.ListDefaulting {
+			return args.DefaultConstraints, nil
+		}
+		break
+	}
+	return systemDefaultTopologySpreadConstraints, nil
+}
+
+func (s *ScheduleTroubleShooter) explainTopologySpreadConstraint(c v1.TopologySpreadConstraint, constraints []v1.TopologySpreadConstraint, eligibleNodes []*framework.NodeInfo, failedNodes []string) (*TopologySpreadExplanation, error) {
+	selector, err := metav1.LabelSelectorAsSelector(c.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	explanation := &TopologySpreadExplanation{
+		TopologyKey:       c.TopologyKey,
+		MaxSkew:           c.MaxSkew,
+		WhenUnsatisfiable: c.WhenUnsatisfiable,
+		LabelSelector:     selector.String(),
+		SelfMatch:         selector.Matches(labels.Set(s.pod.Labels)),
+		ScoringOnly:       c.WhenUnsatisfiable != v1.DoNotSchedule,
+	}
+
+	// The plugin only counts the nodes having the topology keys of all the
+	// constraints of the same kind.
+	keys := make([]string, 0)
+	for _, other := range constraints {
+		if other.WhenUnsatisfiable == c.WhenUnsatisfiable {
+			keys = append(keys, other.TopologyKey)
+		}
+	}
+	matchingPods := make(map[string]int32)
+	nodeDomains := make(map[string]string)
+	for _, n := range eligibleNodes {
+		node := n.Node()
+		if !hasLabels(node, keys) {
+			if !explanation.ScoringOnly {
+				explanation.MissingLabelNodes = append(explanation.MissingLabelNodes, node.Name)
+			}
+			continue
+		}
+		domain := node.Labels[c.TopologyKey]
+		nodeDomains[node.Name] = domain
+		matchingPods[domain] += countMatchingPods(n.Pods, selector, s.pod.Namespace)
+	}
+
+	for domain, count := range matchingPods {
+		explanation.Domains = append(explanation.Domains, TopologyDomain{Value: domain, MatchingPods: count})
+	}
+	sort.Slice(explanation.Domains, func(i, j int) bool {
+		return explanation.Domains[i].Value < explanation.Domains[j].Value
+	})
+	for i, d := range explanation.Domains {
+		if i == 0 || d.MatchingPods < explanation.MinMatchingPods {
+			explanation.MinMatchingPods = d.MatchingPods
+		}
+	}
+
+	// Only the DoNotSchedule constraints are checked by the Filter.
+	if explanation.ScoringOnly {
+		return explanation, nil
+	}
+	selfMatch := int32(0)
+	if explanation.SelfMatch {
+		selfMatch = 1
+	}
+	for _, name := range failedNodes {
+		domain, ok := nodeDomains[name]
+		if !ok {
+			continue
+		}
+		explanation.Nodes = append(explanation.Nodes, TopologySpreadNode{
+			Node:   name,
+			Domain: domain,
+			Skew:   matchingPods[domain] + selfMatch - explanation.MinMatchingPods,
+		})
+	}
+	return explanation, nil
+}
+
+func hasLabels(node *v1.Node, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := node.Labels[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// countMatchingPods counts the pods of the namespace matching the selector,
+// ignoring the terminating ones like the PodTopologySpread plugin does.
+func countMatchingPods(podInfos []*framework.PodInfo, selector labels.Selector, namespace string) int32 {
+	count := int32(0)
+	for _, p := range podInfos {
+		if p.Pod.DeletionTimestamp != nil || p.Pod.Namespace != namespace {
+			continue
+		}
+		if selector.Matches(labels.Set(p.Pod.Labels)) {
+			count++
+		}
+	}
+	return count
+}
+
+// failsPlugin reports whether the plugin rejects the node, at PreFilter or Filter.
+func failsPlugin(n NodeResult, plugin string) bool {
+	if n.Fit {
+		return false
+	}
+	if n.FailedPlugin == plugin {
+		return true
+	}
+	for _, pl := range n.Plugins {
+		if pl.Plugin == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+func formatTopologySpread(explanations []TopologySpreadExplanation) string {
+	var sb strings.Builder
+	for _, e := range explanations {
+		notes := ""
+		if e.ScoringOnly {
+			notes = " (scoring only, never rejects a node)"
+		}
+		if e.Default {
+			notes += " (default constraint of the profile)"
+		}
+		fmt.Fprintf(&sb, "[TopologySpread] %s maxSkew=%d %s%s, selector %q:\n", e.TopologyKey, e.MaxSkew, e.WhenUnsatisfiable, notes, e.LabelSelector)
+		if len(e.Domains) == 0 {
+			sb.WriteString("No eligible node has the topology labels\n")
+		} else {
+			w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "DOMAIN\tMATCHING PODS")
+			for _, d := range e.Domains {
+				fmt.Fprintf(w, "%s\t%d\n", d.Value, d.MatchingPods)
+			}
+			w.Flush()
+		}
+
+		self := "the pod doesn't match the selector"
+		if e.SelfMatch {
+			self = "the pod matches the selector and counts 1"
+		}
+		fmt.Fprintf(&sb, "Min matching pods %d, %s\n", e.MinMatchingPods, self)
+		for _, n := range e.Nodes {
+			verdict := "within"
+			if n.Skew > e.MaxSkew {
+				verdict = "exceeds"
+			}
+			fmt.Fprintf(&sb, "Node %s in %s would create skew %d, %s maxSkew %d\n", n.Node, n.Domain, n.Skew, verdict, e.MaxSkew)
+		}
+		if len(e.MissingLabelNodes) > 0 {
+			fmt.Fprintf(&sb, "Nodes excluded for lacking the topology labels: %s\n", strings.Join(e.MissingLabelNodes, ","))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,179 @@
+package pod
+
+import (
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/podtopologyspread"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+	"reflect"
+	"testing"
+)
+
+func TestExplainTopologySpreadConstraint(t *testing.T) {
+	webPod := func(name, nodeName string) *v1.Pod {
+		return newTestPod(name).Label("app", "web").Node(nodeName).Obj()
+	}
+	nodeInfos := []*framework.NodeInfo{
+		newTestNodeInfo(st.MakeNode().Name("a1").Label("zone", "a").Label("rack", "1").Obj(),
+			webPod("web-1", "a1"), webPod("web-2", "a1"), newTestPod("db").Node("a1").Obj()),
+		newTestNodeInfo(st.MakeNode().Name("a2").Label("zone", "a").Label("rack", "2").Obj(), webPod("web-3", "a2")),
+		newTestNodeInfo(st.MakeNode().Name("b1").Label("zone", "b").Label("rack", "1").Obj()),
+		newTestNodeInfo(st.MakeNode().Name("c1").Label("zone", "c").Obj(), webPod("web-4", "c1")),
+		newTestNodeInfo(st.MakeNode().Name("nolabel").Obj(), webPod("web-5", "nolabel")),
+	}
+	web := st.MakeLabelSelector().Label("app", "web").Obj()
+
+	tests := []struct {
+		name        string
+		pod         *v1.Pod
+		constraint  int
+		wantDomains []TopologyDomain
+		wantMin     int32
+		wantNodes   []TopologySpreadNode
+		wantMissing []string
+	}{
+		{
+			name:        "the pod counts in the skew when it matches the selector",
+			pod:         newTestPod("p").Label("app", "web").SpreadConstraint(1, "zone", v1.DoNotSchedule, web).Obj(),
+			wantDomains: []TopologyDomain{{Value: "a", MatchingPods: 3}, {Value: "b", MatchingPods: 0}, {Value: "c", MatchingPods: 1}},
+			wantMin:     0,
+			wantNodes:   []TopologySpreadNode{{Node: "a1", Domain: "a", Skew: 4}, {Node: "c1", Domain: "c", Skew: 2}},
+			wantMissing: []string{"nolabel"},
+		},
+		{
+			name:        "the pod doesn't count when it doesn't match the selector",
+			pod:         newTestPod("p").SpreadConstraint(1, "zone", v1.DoNotSchedule, web).Obj(),
+			wantDomains: []TopologyDomain{{Value: "a", MatchingPods: 3}, {Value: "b", MatchingPods: 0}, {Value: "c", MatchingPods: 1}},
+			wantMin:     0,
+			wantNodes:   []TopologySpreadNode{{Node: "a1", Domain: "a", Skew: 3}, {Node: "c1", Domain: "c", Skew: 1}},
+			wantMissing: []string{"nolabel"},
+		},
+		{
+			name: "the nodes lacking the key of another DoNotSchedule constraint are not counted",
+			pod: newTestPod("p").Label("app", "web").
+				SpreadConstraint(1, "zone", v1.DoNotSchedule, web).
+				SpreadConstraint(1, "rack", v1.DoNotSchedule, web).Obj(),
+			wantDomains: []TopologyDomain{{Value: "a", MatchingPods: 3}, {Value: "b", MatchingPods: 0}},
+			wantMin:     0,
+			wantNodes:   []TopologySpreadNode{{Node: "a1", Domain: "a", Skew: 4}},
+			wantMissing: []string{"c1", "nolabel"},
+		},
+		{
+			name: "ScheduleAnyway constraints only score",
+			pod: newTestPod("p").Label("app", "web").
+				SpreadConstraint(1, "zone", v1.DoNotSchedule, web).
+				SpreadConstraint(1, "rack", v1.ScheduleAnyway, web).Obj(),
+			constraint:  1,
+			wantDomains: []TopologyDomain{{Value: "1", MatchingPods: 2}, {Value: "2", MatchingPods: 1}},
+			wantMin:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ScheduleTroubleShooter{pod: tt.pod}
+			c := tt.pod.Spec.TopologySpreadConstraints[tt.constraint]
+
+			got, err := s.explainTopologySpreadConstraint(c, tt.pod.Spec.TopologySpreadConstraints, nodeInfos, []string{"a1", "c1", "nolabel"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Domains, tt.wantDomains) {
+				t.Errorf("domains: want %v, got %v", tt.wantDomains, got.Domains)
+			}
+			if got.MinMatchingPods != tt.wantMin {
+				t.Errorf("min matching pods: want %d, got %d", tt.wantMin, got.MinMatchingPods)
+			}
+			if !reflect.DeepEqual(got.Nodes, tt.wantNodes) {
+				t.Errorf("nodes: want %v, got %v", tt.wantNodes, got.Nodes)
+			}
+			if !reflect.DeepEqual(got.MissingLabelNodes, tt.wantMissing) {
+				t.Errorf("missing label nodes: want %v, got %v", tt.wantMissing, got.MissingLabelNodes)
+			}
+			if want := c.WhenUnsatisfiable == v1.ScheduleAnyway; got.ScoringOnly != want {
+				t.Errorf("scoring only: want %v, got %v", want, got.ScoringOnly)
+			}
+		})
+	}
+}
+
+func TestTopologySpreadConstraints(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       v1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(service), 0)
+	factory.Core().V1().Services().Informer()
+	factory.Core().V1().ReplicationControllers().Informer()
+	factory.Apps().V1().ReplicaSets().Informer()
+	factory.Apps().V1().StatefulSets().Informer()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	web := st.MakeLabelSelector().Label("app", "web").Obj()
+	listed := v1.TopologySpreadConstraint{MaxSkew: 1, TopologyKey: "zone", WhenUnsatisfiable: v1.DoNotSchedule}
+	profile := func(args *config.PodTopologySpreadArgs) *config.KubeSchedulerProfile {
+		return &config.KubeSchedulerProfile{PluginConfig: []config.PluginConfig{{Name: podtopologyspread.Name, Args: args}}}
+	}
+	withSelector := func(constraints []v1.TopologySpreadConstraint) []v1.TopologySpreadConstraint {
+		result := make([]v1.TopologySpreadConstraint, 0, len(constraints))
+		for _, c := range constraints {
+			c.LabelSelector = web
+			result = append(result, c)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name    string
+		pod     *v1.Pod
+		profile *config.KubeSchedulerProfile
+		want    []v1.TopologySpreadConstraint
+	}{
+		{
+			name:    "the constraints of the pod",
+			pod:     newTestPod("p").Label("app", "web").SpreadConstraint(1, "rack", v1.DoNotSchedule, web).Obj(),
+			profile: profile(&config.PodTopologySpreadArgs{DefaultingType: config.SystemDefaulting}),
+			want:    []v1.TopologySpreadConstraint{{MaxSkew: 1, TopologyKey: "rack", WhenUnsatisfiable: v1.DoNotSchedule, LabelSelector: web}},
+		},
+		{
+			name:    "the system defaults selecting the pods of the Service",
+			pod:     newTestPod("p").Label("app", "web").Obj(),
+			profile: profile(&config.PodTopologySpreadArgs{DefaultingType: config.SystemDefaulting}),
+			want:    withSelector(systemDefaultTopologySpreadConstraints),
+		},
+		{
+			name: "the listed defaults",
+			pod:  newTestPod("p").Label("app", "web").Obj(),
+			profile: profile(&config.PodTopologySpreadArgs{
+				DefaultingType:     config.ListDefaulting,
+				DefaultConstraints: []v1.TopologySpreadConstraint{listed},
+			}),
+			want: withSelector([]v1.TopologySpreadConstraint{listed}),
+		},
+		{
+			name:    "no default without a Service or owner selecting the pod",
+			pod:     newTestPod("p").Label("app", "db").Obj(),
+			profile: profile(&config.PodTopologySpreadArgs{DefaultingType: config.SystemDefaulting}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ScheduleTroubleShooter{pod: tt.pod, profile: tt.profile, informerFactory: factory}
+			got, err := s.topologySpreadConstraints()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The selector parsed back from the string has empty match expressions.
+			if !apiequality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
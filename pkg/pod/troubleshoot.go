@@ -5,19 +5,24 @@ import (
 	"fmt"
 	"github.com/briandowns/spinner"
 	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/kube-scheduler/config/v1beta3"
 	"k8s.io/kubernetes/pkg/scheduler/apis/config"
-	"k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/parallelize"
 	frameworkplugins "k8s.io/kubernetes/pkg/scheduler/framework/plugins"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	"os"
+	"sort"
 	"strings"
 	"time"
 	"troubleshooter/pkg"
@@ -25,10 +30,111 @@ import (
 
 type ScheduleTroubleShooter struct {
 	pod      *v1.Pod
-	nodeInfo *framework.NodeInfo
+	nodeName string
+	// nodeInfos are the nodes the pod is evaluated against, while snapshot
+	// holds every node of the cluster for the plugins looking across nodes.
+	nodeInfos []*framework.NodeInfo
+	snapshot  *TroubleShootPodScheduleSnapshotSharedLister
+	nominator *TroubleShootPodScheduleNominator
+	// workload owns the pods to evaluate instead of a single pod.
+	workload *workloadRef
+	// hypothetical is set when the pod comes from a manifest, not from the cluster.
+	hypothetical bool
 
-	kubeConfig *rest.Config
-	client     *kubernetes.Clientset
+	schedulerConfig   *config.KubeSchedulerConfiguration
+	profile           *config.KubeSchedulerProfile
+	outOfTreeRegistry frameworkruntime.Registry
+	framework         framework.Framework
+	// skippedPlugins are the plugins of the default profile the profile disables.
+	skippedPlugins []string
+
+	outputFormat string
+
+	kubeConfig      *rest.Config
+	client          kubernetes.Interface
+	informerFactory informers.SharedInformerFactory
+}
+
+const defaultCacheSyncTimeout = 30 * time.Second
+
+type scheduleOptions struct {
+	schedulerConfigPath string
+	profileName         string
+	cacheSyncTimeout    time.Duration
+	outputFormat        string
+	// fromFiles and fromDirs are the manifests loaded instead of accessing a cluster.
+	fromFiles []string
+	fromDirs  []string
+	// podManifest is the manifest of a pod not created yet, evaluated instead of an existing pod.
+	podManifest string
+	// workload is the kind/name of the workload whose pending pods are evaluated.
+	workload string
+	// outOfTreeRegistry adds to the plugins registered by RegisterOutOfTreePlugin.
+	outOfTreeRegistry frameworkruntime.Registry
+}
+
+type ScheduleOption func(*scheduleOptions)
+
+// WithSchedulerConfig loads the KubeSchedulerConfiguration used by the cluster
+// instead of the default one.
+func WithSchedulerConfig(path string) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.schedulerConfigPath = path
+	}
+}
+
+// WithCacheSyncTimeout bounds the time waiting for the informer caches to sync.
+func WithCacheSyncTimeout(timeout time.Duration) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.cacheSyncTimeout = timeout
+	}
+}
+
+// WithOutputFormat prints the verdict as a table, json or yaml.
+func WithOutputFormat(format string) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.outputFormat = format
+	}
+}
+
+// WithManifests troubleshoots against the objects of the yaml or json manifests in
+// the files and directories, instead of a live cluster.
+func WithManifests(files, dirs []string) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.fromFiles = files
+		o.fromDirs = dirs
+	}
+}
+
+// WithPodManifest evaluates the pod of the Pod or workload manifest, which doesn't
+// have to exist in the cluster, instead of looking the pod up by name.
+func WithPodManifest(path string) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.podManifest = path
+	}
+}
+
+// WithWorkload evaluates the pending pods of the workload, e.g. deployment/foo,
+// instead of a single pod.
+func WithWorkload(ref string) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.workload = ref
+	}
+}
+
+// WithProfile selects the profile by scheduler name instead of the pod's spec.schedulerName.
+func WithProfile(profileName string) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.profileName = profileName
+	}
+}
+
+// WithOutOfTreePlugins makes the plugins of the registry, not shipped with kube-scheduler,
+// available to the profiles of the scheduler config.
+func WithOutOfTreePlugins(registry frameworkruntime.Registry) ScheduleOption {
+	return func(o *scheduleOptions) {
+		o.outOfTreeRegistry = registry
+	}
 }
 
 func NewScheduleTroubleShooter(
@@ -36,75 +142,215 @@ func NewScheduleTroubleShooter(
 	podName,
 	podNamespace,
 	nodeName string,
-) *ScheduleTroubleShooter {
+	opts ...ScheduleOption,
+) (*ScheduleTroubleShooter, error) {
 	ctx := context.Background()
 
-	kubeConfig, err := pkg.LoadKubeConfigByPath(kubeConfigPath)
-	if err != nil {
-		panic(err)
+	options := scheduleOptions{
+		cacheSyncTimeout: defaultCacheSyncTimeout,
+		outputFormat:     OutputTable,
+	}
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	if len(podName) == 0 {
-		panic(fmt.Errorf("podName should not be empty"))
+	if err := ValidateOutputFormat(options.outputFormat); err != nil {
+		return nil, pkg.NewConfigError(err)
 	}
 
-	if len(podNamespace) == 0 {
-		podNamespace = ""
+	schedulerConfig, err := pkg.LoadSchedulerConfigByPath(options.schedulerConfigPath)
+	if err != nil {
+		return nil, pkg.NewConfigError(err)
+	}
+
+	numPodSources := 0
+	for _, source := range []string{podName, options.podManifest, options.workload} {
+		if len(source) != 0 {
+			numPodSources++
+		}
+	}
+	if numPodSources == 0 {
+		return nil, pkg.NewConfigError(fmt.Errorf("podName should not be empty"))
+	}
+	if numPodSources > 1 {
+		return nil, pkg.NewConfigError(fmt.Errorf("podName, pod manifest and workload are mutually exclusive"))
 	}
 
-	if len(nodeName) == 0 {
-		panic(fmt.Errorf("nodeName should not be empty"))
+	clientSet, kubeConfig, err := buildClientSet(kubeConfigPath, options)
+	if err != nil {
+		return nil, pkg.NewConfigError(err)
 	}
 
-	clientSet, err := kubernetes.NewForConfig(kubeConfig)
+	var pod *v1.Pod
+	var workload *workloadRef
+	switch {
+	case len(options.podManifest) != 0:
+		pod, err = hypotheticalPod(ctx, clientSet, options.podManifest, podNamespace)
+	case len(options.workload) != 0:
+		workload, pod, err = loadWorkload(ctx, clientSet, options.workload, podNamespace)
+	default:
+		pod, err = findPod(ctx, clientSet, podName, podNamespace)
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	pod, err := findPod(ctx, clientSet, podName, podNamespace)
+	profile, err := selectProfile(schedulerConfig, pod, options)
 	if err != nil {
-		panic(err)
+		return nil, pkg.NewConfigError(err)
 	}
 
-	node, err := findNode(ctx, clientSet, nodeName)
+	outOfTreeRegistry, err := outOfTreePlugins(options.outOfTreeRegistry)
 	if err != nil {
-		panic(err)
+		return nil, pkg.NewConfigError(err)
+	}
+
+	informerFactory := NewInformerFactory(clientSet, 0)
+	// Registers the node and pod informers the snapshot is taken from, the storage
+	// ones the volumes of the pod are diagnosed from, and the namespace one the
+	// namespace selectors of the pod affinity terms are resolved with, and the
+	// PodDisruptionBudget one the preemption dry run lists the budgets from.
+	informerFactory.Core().V1().Nodes().Informer()
+	informerFactory.Core().V1().Pods().Informer()
+	informerFactory.Core().V1().Namespaces().Informer()
+	informerFactory.Core().V1().PersistentVolumeClaims().Informer()
+	informerFactory.Core().V1().PersistentVolumes().Informer()
+	informerFactory.Storage().V1().StorageClasses().Informer()
+	informerFactory.Storage().V1().CSINodes().Informer()
+	informerFactory.Storage().V1().CSIDrivers().Informer()
+	informerFactory.Storage().V1beta1().CSIStorageCapacities().Informer()
+	informerFactory.Policy().V1().PodDisruptionBudgets().Informer()
+	if workload != nil && workload.kind == pkg.KindDeployment {
+		// The pods of a Deployment are owned by its ReplicaSets.
+		informerFactory.Apps().V1().ReplicaSets().Informer()
+	}
+
+	s := &ScheduleTroubleShooter{
+		pod:          pod,
+		nodeName:     nodeName,
+		snapshot:     NewTroubleShootPodScheduleSnapshotSharedLister(nil, nil),
+		nominator:    NewTroubleShootPodScheduleNominator(),
+		workload:     workload,
+		hypothetical: len(options.podManifest) != 0,
+		kubeConfig:   kubeConfig,
+		client:       clientSet,
+
+		informerFactory:   informerFactory,
+		schedulerConfig:   schedulerConfig,
+		profile:           profile,
+		outOfTreeRegistry: outOfTreeRegistry,
+		outputFormat:      options.outputFormat,
 	}
 
-	nodeInfo, err := buildNodeInfo(ctx, clientSet, node)
+	// Instantiating the plugins registers the informers they list from, so the
+	// framework is built before the informers are started.
+	s.framework, err = s.buildScheduleFramework()
 	if err != nil {
-		panic(err)
+		return nil, pkg.NewConfigError(err)
+	}
+	s.skippedPlugins, err = s.findSkippedPlugins(s.framework)
+	if err != nil {
+		return nil, pkg.NewConfigError(err)
+	}
+
+	if err := waitForCacheSync(ctx, informerFactory, options.cacheSyncTimeout); err != nil {
+		return nil, err
 	}
 
-	return &ScheduleTroubleShooter{
-		pod:        pod,
-		nodeInfo:   nodeInfo,
-		kubeConfig: kubeConfig,
-		client:     clientSet,
+	if err := s.takeSnapshot(); err != nil {
+		return nil, err
 	}
+	return s, nil
 }
 
-func buildNodeInfo(ctx context.Context, cs *clientset.Clientset, node *v1.Node) (*framework.NodeInfo, error) {
-	pods := make([]*v1.Pod, 0)
-	podList, err := cs.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-		FieldSelector: "spec.nodeName=" + node.Name,
-	})
+// takeSnapshot takes a snapshot of every node and the pods assigned to them from
+// the informer cache, like kube-scheduler does from its scheduler cache.
+func (s *ScheduleTroubleShooter) takeSnapshot() error {
+	nodes, err := s.informerFactory.Core().V1().Nodes().Lister().List(labels.Everything())
 	if err != nil {
-		if !errors.IsNotFound(err) {
-			return nil, err
+		return err
+	}
+	pods, err := s.informerFactory.Core().V1().Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	pods = activePods(pods)
+	s.snapshot.Update(pods, nodes)
+	s.nominator.SetNominatedPods(pods)
+
+	s.nodeInfos, err = s.snapshot.NodeInfos().List()
+	if err != nil {
+		return err
+	}
+	if len(s.nodeInfos) == 0 {
+		return fmt.Errorf("No nodes found in cluster\n")
+	}
+	if len(s.nodeName) != 0 {
+		nodeInfo, err := s.snapshot.NodeInfos().Get(s.nodeName)
+		if err != nil {
+			return pkg.NewConfigError(fmt.Errorf("Node %s not found\n", s.nodeName))
 		}
-	} else {
-		for _, p := range podList.Items {
-			pods = append(pods, p.DeepCopy())
+		s.nodeInfos = []*framework.NodeInfo{nodeInfo}
+	}
+	return nil
+}
+
+// buildClientSet connects to the cluster of the kubeconfig, unless manifests are
+// given, which are served by a fake clientset instead.
+func buildClientSet(kubeConfigPath string, options scheduleOptions) (kubernetes.Interface, *rest.Config, error) {
+	if len(options.fromFiles) == 0 && len(options.fromDirs) == 0 {
+		kubeConfig, err := pkg.LoadKubeConfigByPath(kubeConfigPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		clientSet, err := kubernetes.NewForConfig(kubeConfig)
+		if err != nil {
+			return nil, nil, err
 		}
+		return clientSet, kubeConfig, nil
 	}
 
-	ni := framework.NewNodeInfo(pods...)
-	ni.SetNode(node)
-	return ni, nil
+	objects, err := pkg.LoadObjectsFromFiles(options.fromFiles...)
+	if err != nil {
+		return nil, nil, err
+	}
+	dirObjects, err := pkg.LoadObjectsFromDirs(options.fromDirs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientSet, err := pkg.NewOfflineClientSet(append(objects, dirObjects...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientSet, &rest.Config{}, nil
+}
+
+// selectProfile picks the profile the pod would be scheduled with. Without an
+// explicit scheduler config we cannot know the cluster's profiles, so the
+// default one is used.
+func selectProfile(cfg *config.KubeSchedulerConfiguration, pod *v1.Pod, options scheduleOptions) (*config.KubeSchedulerProfile, error) {
+	schedulerName := options.profileName
+	if len(schedulerName) == 0 {
+		if len(options.schedulerConfigPath) == 0 {
+			return &cfg.Profiles[0], nil
+		}
+		schedulerName = pod.Spec.SchedulerName
+	}
+	if len(schedulerName) == 0 {
+		schedulerName = v1.DefaultSchedulerName
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for i := range cfg.Profiles {
+		if cfg.Profiles[i].SchedulerName == schedulerName {
+			return &cfg.Profiles[i], nil
+		}
+		names = append(names, cfg.Profiles[i].SchedulerName)
+	}
+	return nil, fmt.Errorf("Profile %s not found in scheduler config, available profiles: %s\n", schedulerName, strings.Join(names, ","))
 }
 
-func findPod(ctx context.Context, cs *kubernetes.Clientset, name, namespace string) (*v1.Pod, error) {
+func findPod(ctx context.Context, cs kubernetes.Interface, name, namespace string) (*v1.Pod, error) {
 	var pod *v1.Pod
 	if len(namespace) == 0 {
 		pods, err := cs.CoreV1().Pods("").List(ctx, metav1.ListOptions{
@@ -112,21 +358,27 @@ func findPod(ctx context.Context, cs *kubernetes.Clientset, name, namespace stri
 		})
 		if err != nil {
 			if errors.IsNotFound(err) {
-				return nil, fmt.Errorf("Pod %s in all namespaces not found\n", name)
+				return nil, pkg.NewConfigError(fmt.Errorf("Pod %s in all namespaces not found\n", name))
 			} else {
 				return nil, err
 			}
 		}
-		if len(pods.Items) == 0 {
-			return nil, fmt.Errorf("Pod %s in all namespaces not found\n", name)
+		// The fake clientset of the offline mode ignores field selectors.
+		for i := range pods.Items {
+			if pods.Items[i].Name == name {
+				pod = pods.Items[i].DeepCopy()
+				break
+			}
+		}
+		if pod == nil {
+			return nil, pkg.NewConfigError(fmt.Errorf("Pod %s in all namespaces not found\n", name))
 		}
-		pod = pods.Items[0].DeepCopy()
 	} else {
 		var err error
 		pod, err = cs.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
 			if errors.IsNotFound(err) {
-				return nil, fmt.Errorf("Pod %s in namespace %s not found\n", name, namespace)
+				return nil, pkg.NewConfigError(fmt.Errorf("Pod %s in namespace %s not found\n", name, namespace))
 			} else {
 				return nil, err
 			}
@@ -135,80 +387,384 @@ func findPod(ctx context.Context, cs *kubernetes.Clientset, name, namespace stri
 	return pod, nil
 }
 
-func findNode(ctx context.Context, cs *kubernetes.Clientset, name string) (*v1.Node, error) {
-	node, err := cs.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+// hypotheticalPod loads the pod of the manifest the way the API server would
+// admit it, resolving its namespace and priority.
+func hypotheticalPod(ctx context.Context, cs kubernetes.Interface, path, namespace string) (*v1.Pod, error) {
+	if len(namespace) == 0 {
+		namespace = metav1.NamespaceDefault
+	}
+	pod, err := pkg.LoadPodFromManifest(path, namespace)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return nil, fmt.Errorf("Node %s not found\n", name)
-		} else {
-			return nil, err
+		return nil, pkg.NewConfigError(err)
+	}
+	// A pod with a node name is bound by the API server as is, kube-scheduler never sees it.
+	if len(pod.Spec.NodeName) != 0 {
+		return nil, pkg.NewConfigError(fmt.Errorf("Pod of manifest %s sets spec.nodeName %s, it is not scheduled by kube-scheduler\n", path, pod.Spec.NodeName))
+	}
+
+	if err := resolvePriority(ctx, cs, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// resolvePriority sets the priority of the pod from its PriorityClass, or the
+// global default one, like the Priority admission plugin does.
+func resolvePriority(ctx context.Context, cs kubernetes.Interface, pod *v1.Pod) error {
+	if pod.Spec.Priority != nil {
+		return nil
+	}
+
+	var priorityClass *schedulingv1.PriorityClass
+	if len(pod.Spec.PriorityClassName) != 0 {
+		pc, err := cs.SchedulingV1().PriorityClasses().Get(ctx, pod.Spec.PriorityClassName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return pkg.NewConfigError(fmt.Errorf("PriorityClass %s not found\n", pod.Spec.PriorityClassName))
+			}
+			return err
+		}
+		priorityClass = pc
+	} else {
+		pcList, err := cs.SchedulingV1().PriorityClasses().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
 		}
+		for i := range pcList.Items {
+			if pcList.Items[i].GlobalDefault {
+				priorityClass = &pcList.Items[i]
+				break
+			}
+		}
+	}
+	if priorityClass == nil {
+		return nil
 	}
-	return node, nil
+
+	pod.Spec.PriorityClassName = priorityClass.Name
+	pod.Spec.Priority = &priorityClass.Value
+	if pod.Spec.PreemptionPolicy == nil {
+		pod.Spec.PreemptionPolicy = priorityClass.PreemptionPolicy
+	}
+	return nil
 }
 
-func (s *ScheduleTroubleShooter) Execute() {
-	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond)
+// Execute prints the verdict of the pod schedule and returns it, so the caller
+// can tell the outcome apart without parsing the output.
+func (s *ScheduleTroubleShooter) Execute() (Verdict, error) {
+	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
 	sp.Start()
 
 	ctx := context.Background()
-	conclusion, err := s.executeCore(ctx)
+	report, err := s.executeCore(ctx)
 	sp.Stop()
 	if err != nil {
-		panic(err)
+		return "", err
 	}
-	fmt.Println(conclusion)
+	if err := PrintScheduleReport(os.Stdout, s.outputFormat, report); err != nil {
+		return "", err
+	}
+	return report.Verdict, nil
 }
 
-func (s *ScheduleTroubleShooter) executeCore(ctx context.Context) (string, error) {
-	for _, pi := range s.nodeInfo.Pods {
-		if ((s.pod.Namespace != "" && s.pod.Namespace == pi.Pod.Namespace) || (s.pod.Namespace == "")) && s.pod.Name == pi.Pod.Name {
-			return fmt.Sprintf("Pod %s already on node %s", s.pod.Name, s.nodeInfo.Node().Name), nil
+func (s *ScheduleTroubleShooter) executeCore(ctx context.Context) (*ScheduleReport, error) {
+	fw := s.framework
+	report := newScheduleReport(s.pod, s.nodeName, fw.ProfileName())
+
+	var err error
+	if len(s.nodeName) == 0 {
+		err = s.executeOnCluster(ctx, fw, report)
+	} else {
+		err = s.executeOnNode(ctx, fw, report)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addResourceFits(s.pod, report.Nodes, s.nodeInfos)
+	if report.Verdict == VerdictUnschedulable {
+		report.Suggestions = suggestRemediations(s.pod, report.Nodes, s.nodeInfos)
+		report.Volumes, err = s.diagnoseVolumes(report)
+		if err != nil {
+			return nil, err
+		}
+		report.TopologySpread, err = s.explainTopologySpread(report)
+		if err != nil {
+			return nil, err
+		}
+		report.InterPodAffinity, err = s.explainInterPodAffinity(report)
+		if err != nil {
+			return nil, err
+		}
+		report.NodeAffinity, err = s.explainNodeAffinity(report)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	status := framework.NewCycleState()
-	fw, err := s.buildScheduleFramework()
+	// Pods not created yet have no events, nor do nodes evaluated on their own
+	// compare with the reasons kube-scheduler counts across every node.
+	if !s.hypothetical && len(s.nodeName) == 0 && report.Verdict != VerdictAlreadyScheduled {
+		report.Scheduler, err = s.compareWithScheduler(ctx, report)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report.SkippedPlugins = s.skippedPlugins
+	return report, nil
+}
+
+// findSkippedPlugins lists the PreFilter and Filter plugins run by the default
+// profile of kube-scheduler but disabled by the selected profile. It instantiates
+// the default plugins, so it runs once rather than on every evaluation.
+func (s *ScheduleTroubleShooter) findSkippedPlugins(fw framework.Framework) ([]string, error) {
+	defaultCfg, err := pkg.DefaultSchedulerConfig()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	defaultFw, err := NewFramework(
+		frameworkplugins.NewInTreeRegistry(),
+		&defaultCfg.Profiles[0],
+		WithClientSet(s.client),
+		WithKubeConfig(s.kubeConfig),
+		WithInformerFactory(NewInformerFactory(s.client, 0)),
+		WithSnapshotSharedLister(fw.SnapshotSharedLister()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := fw.ListPlugins()
+	defaultPlugins := defaultFw.ListPlugins()
+	skipped := sets.NewString()
+	skipped.Insert(disabledPlugins(defaultPlugins.PreFilter, plugins.PreFilter)...)
+	skipped.Insert(disabledPlugins(defaultPlugins.Filter, plugins.Filter)...)
+	return skipped.List(), nil
+}
+
+func disabledPlugins(defaultSet, set config.PluginSet) []string {
+	enabled := sets.NewString()
+	for _, pl := range set.Enabled {
+		enabled.Insert(pl.Name)
+	}
+
+	disabled := make([]string, 0)
+	for _, pl := range defaultSet.Enabled {
+		if !enabled.Has(pl.Name) {
+			disabled = append(disabled, pl.Name)
+		}
+	}
+	return disabled
+}
+
+func (s *ScheduleTroubleShooter) executeOnNode(ctx context.Context, fw framework.Framework, report *ScheduleReport) error {
+	nodeInfo := s.nodeInfos[0]
+	for _, pi := range nodeInfo.Pods {
+		if ((s.pod.Namespace != "" && s.pod.Namespace == pi.Pod.Namespace) || (s.pod.Namespace == "")) && s.pod.Name == pi.Pod.Name {
+			report.Verdict = VerdictAlreadyScheduled
+			report.Message = fmt.Sprintf("Pod %s already on node %s", s.pod.Name, nodeInfo.Node().Name)
+			return nil
+		}
+	}
+
+	// The pod is evaluated as if it were pending, otherwise it would count
+	// against itself in the plugins looking across nodes.
+	if err := s.removeAssignedPod(); err != nil {
+		return err
 	}
 
+	status := framework.NewCycleState()
 	preFilterPluginStatuses := fw.RunPreFilterPlugins(ctx, status, s.pod)
+	report.PreFilter = newPluginResult(preFilterPluginStatuses)
 	if !preFilterPluginStatuses.IsSuccess() {
-		return "", preFilterPluginStatuses.AsError()
+		if !preFilterPluginStatuses.IsUnschedulable() {
+			return preFilterPluginStatuses.AsError()
+		}
+		report.Verdict = VerdictUnschedulable
+		report.Message = "Reasons are:"
+		report.Nodes = []NodeResult{{
+			Name:         nodeInfo.Node().Name,
+			FailedPlugin: preFilterPluginStatuses.FailedPlugin(),
+			Reasons:      preFilterPluginStatuses.Reasons(),
+		}}
+		return nil
+	}
+
+	filterPluginStatuses, err := runFilterPluginsWithNominatedPods(ctx, fw, status, s.pod, nodeInfo)
+	if err != nil {
+		return err
+	}
+	fitsPlugins := len(filterPluginStatuses) == 0
+	if fitsPlugins {
+		// The extenders only see the nodes passing the filter plugins.
+		_, extenderStatuses, err := runExtenderFilters(fw.Extenders(), s.pod, []*framework.NodeInfo{nodeInfo})
+		if err != nil {
+			return err
+		}
+		filterPluginStatuses = extenderStatuses[nodeInfo.Node().Name]
+	}
+	nodeResult := NodeResult{
+		Name:    nodeInfo.Node().Name,
+		Fit:     len(filterPluginStatuses) == 0,
+		Plugins: newPluginStatuses(filterPluginStatuses),
+	}
+	if nodeResult.Fit {
+		report.Verdict = VerdictSchedulable
+		report.Message = "Pod can be scheduled to nodes, please wait..."
+		report.Nodes = []NodeResult{nodeResult}
+		return nil
 	}
 
-	filterPluginStatuses := fw.RunFilterPlugins(ctx, status, s.pod, s.nodeInfo)
-	if len(filterPluginStatuses) == 0 {
-		return fmt.Sprintf("[Success] Pod can be scheduled to nodes, please wait..."), nil
+	merged := filterPluginStatuses.Merge()
+	if merged.Code() == framework.Error {
+		return merged.AsError()
+	}
+	nodeResult.FailedPlugin = merged.FailedPlugin()
+	nodeResult.Reasons = merged.Reasons()
+	if !fitsPlugins {
+		reservedBy, err := nominatedPodsReservingNode(ctx, fw, status, s.pod, nodeInfo)
+		if err != nil {
+			return err
+		}
+		nodeResult.ReservedFor = newPrioritizedPodReferences(reservedBy)
+	}
+
+	report.Verdict = VerdictUnschedulable
+	report.Message = "Reasons are:"
+	report.Nodes = []NodeResult{nodeResult}
+	return nil
+}
+
+// removeAssignedPod removes the pod from the snapshot of the node it is assigned to.
+func (s *ScheduleTroubleShooter) removeAssignedPod() error {
+	if len(s.pod.Spec.NodeName) == 0 {
+		return nil
+	}
+	nodeInfo, err := s.snapshot.NodeInfos().Get(s.pod.Spec.NodeName)
+	if err != nil {
+		// The node the pod is assigned to is gone.
+		return nil
+	}
+	for _, pi := range nodeInfo.Pods {
+		// Succeeded and failed pods are not in the snapshot.
+		if pi.Pod.UID == s.pod.UID {
+			return nodeInfo.RemovePod(s.pod)
+		}
+	}
+	return nil
+}
+
+// executeOnCluster evaluates the pod against every node of the cluster the same
+// way kube-scheduler's findNodesThatFitPod does, and summarizes the result like
+// the FitError reported in FailedScheduling events.
+func (s *ScheduleTroubleShooter) executeOnCluster(ctx context.Context, fw framework.Framework, report *ScheduleReport) error {
+	if len(s.pod.Spec.NodeName) != 0 {
+		report.Verdict = VerdictAlreadyScheduled
+		report.Message = fmt.Sprintf("Pod %s already on node %s", s.pod.Name, s.pod.Spec.NodeName)
+		return nil
+	}
+
+	state := framework.NewCycleState()
+	result, err := findNodesThatFitPod(ctx, fw, state, s.pod, s.nodeInfos)
+	if err != nil {
+		return err
+	}
+	s.fillClusterVerdict(report, result)
+	if len(result.feasibleNodes) != 0 || !fw.HasPostFilterPlugins() {
+		return nil
+	}
+
+	report.Preemption, err = dryRunPostFilter(ctx, fw, state, s.pod, result.diagnosis.NodeToStatusMap)
+	return err
+}
+
+// dryRunPostFilter simulates the preemption kube-scheduler would attempt once
+// the pod failed to fit every node.
+func dryRunPostFilter(ctx context.Context, fw framework.Framework, state *framework.CycleState, pod *v1.Pod, m framework.NodeToStatusMap) (*PreemptionResult, error) {
+	_, status := fw.RunPostFilterPlugins(ctx, state, pod, m)
+	if status.Code() == framework.Error {
+		return nil, status.AsError()
+	}
+
+	c, err := state.Read(PreemptionDryRunStateKey)
+	if err != nil {
+		return &PreemptionResult{Reason: strings.Join(status.Reasons(), ",")}, nil
+	}
+	result := c.(*PreemptionDryRunResult)
+	if len(result.NominatedNodeName) == 0 {
+		return &PreemptionResult{Plugin: result.PluginName, Reason: result.Reason}, nil
+	}
+
+	preemption := &PreemptionResult{
+		Plugin:        result.PluginName,
+		Possible:      true,
+		NominatedNode: result.NominatedNodeName,
+		Victims:       make([]Victim, 0, len(result.Victims)),
+	}
+	for _, victim := range result.Victims {
+		v := Victim{PrioritizedPodReference: newPrioritizedPodReferences([]*v1.Pod{victim})[0]}
+		if pdb, ok := result.VictimsPDBs[victim]; ok {
+			v.PodDisruptionBudget = pdb.Namespace + "/" + pdb.Name
+		}
+		preemption.Victims = append(preemption.Victims, v)
+	}
+	return preemption, nil
+}
+
+func (s *ScheduleTroubleShooter) fillClusterVerdict(report *ScheduleReport, result *filterResult) {
+	diagnosis := result.diagnosis
+	report.Nodes = make([]NodeResult, 0, len(s.nodeInfos))
+	for _, n := range s.nodeInfos {
+		nodeName := n.Node().Name
+		status, ok := diagnosis.NodeToStatusMap[nodeName]
+		if !ok {
+			report.Nodes = append(report.Nodes, NodeResult{Name: nodeName, Fit: true})
+			continue
+		}
+		report.Nodes = append(report.Nodes, NodeResult{
+			Name:         nodeName,
+			FailedPlugin: status.FailedPlugin(),
+			Reasons:      status.Reasons(),
+			Plugins:      newPluginStatuses(result.pluginStatuses[nodeName]),
+			ReservedFor:  newPrioritizedPodReferences(result.reservedBy[nodeName]),
+		})
+	}
+
+	numFeasible := len(s.nodeInfos) - len(diagnosis.NodeToStatusMap)
+	if numFeasible > 0 {
+		report.Verdict = VerdictSchedulable
+		report.Message = fmt.Sprintf("%d/%d nodes are available, please wait...", numFeasible, len(s.nodeInfos))
 	} else {
-		plgStatusList := make([]string, 0, len(filterPluginStatuses))
-		for plg, status := range filterPluginStatuses {
-			plgStatusList = append(plgStatusList, fmt.Sprintf("%s: %s", plg, strings.Join(status.Reasons(), ",")))
+		fitErr := &framework.FitError{
+			Pod:         s.pod,
+			NumAllNodes: len(s.nodeInfos),
+			Diagnosis:   diagnosis,
 		}
-		return fmt.Sprintf("[Fail] Reasons are:\n%s", strings.Join(plgStatusList, "\n")), nil
+		report.Verdict = VerdictUnschedulable
+		report.Message = fitErr.Error()
 	}
 }
 
 func (s *ScheduleTroubleShooter) buildScheduleFramework() (framework.Framework, error) {
-	var versionedCfg v1beta3.KubeSchedulerConfiguration
-	scheme.Scheme.Default(&versionedCfg)
-	cfg := config.KubeSchedulerConfiguration{}
-	if err := scheme.Scheme.Convert(&versionedCfg, &cfg, nil); err != nil {
+	registry := frameworkplugins.NewInTreeRegistry()
+
+	extenders, profile, err := buildExtenders(s.schedulerConfig, s.profile)
+	if err != nil {
 		return nil, err
 	}
 
-	profile := cfg.Profiles[0]
-	registry := frameworkplugins.NewInTreeRegistry()
-	informFactory := NewInformerFactory(s.client, 0)
-
 	return NewFramework(
 		registry,
-		&profile,
+		profile,
 		WithClientSet(s.client),
 		WithKubeConfig(s.kubeConfig),
-		WithInformerFactory(informFactory),
-		WithSnapshotSharedLister(NewTroubleShootPodScheduleSnapshotSharedLister(s.nodeInfo)),
+		WithInformerFactory(s.informerFactory),
+		WithParallelizer(parallelize.NewParallelizer(int(s.schedulerConfig.Parallelism))),
+		WithSnapshotSharedLister(s.snapshot),
+		WithPodNominator(s.nominator),
+		WithExtenders(extenders),
+		WithOutOfTreeRegistry(s.outOfTreeRegistry),
 	)
 }
 
@@ -218,6 +774,57 @@ func NewInformerFactory(cs clientset.Interface, resyncPeriod time.Duration) info
 	return informerFactory
 }
 
+// waitForCacheSync starts every informer registered in the factory and waits for
+// their caches to sync, failing with the informers still not synced on timeout.
+func waitForCacheSync(ctx context.Context, informerFactory informers.SharedInformerFactory, timeout time.Duration) error {
+	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	sp.Suffix = " Waiting for informer caches to sync"
+	sp.Start()
+	defer sp.Stop()
+
+	informerFactory.Start(ctx.Done())
+
+	// WaitForCacheSync checks every informer once before honoring the closed
+	// channel, which reports the progress without blocking.
+	poll := make(chan struct{})
+	close(poll)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+	for {
+		synced := informerFactory.WaitForCacheSync(poll)
+		unsynced := make([]string, 0)
+		for informerType, ok := range synced {
+			if !ok {
+				unsynced = append(unsynced, informerType.String())
+			}
+		}
+		if len(unsynced) == 0 {
+			return nil
+		}
+		sp.Suffix = fmt.Sprintf(" Waiting for informer caches to sync (%d/%d)", len(synced)-len(unsynced), len(synced))
+
+		select {
+		case <-deadline:
+			sort.Strings(unsynced)
+			return pkg.NewTimeoutError(fmt.Errorf("Timed out after %v waiting for informer caches to sync: %s, check the kubeconfig is allowed to list and watch them\n", timeout, strings.Join(unsynced, ",")))
+		case <-ticker.C:
+		}
+	}
+}
+
+// activePods drops the succeeded and failed pods, which the pod informer
+// doesn't filter out when the fake clientset ignores its field selector.
+func activePods(pods []*v1.Pod) []*v1.Pod {
+	active := make([]*v1.Pod, 0, len(pods))
+	for _, p := range pods {
+		if p.Status.Phase != v1.PodSucceeded && p.Status.Phase != v1.PodFailed {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
 func newPodInformer(cs clientset.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
 	selector := fmt.Sprintf("status.phase!=%v,status.phase!=%v", v1.PodSucceeded, v1.PodFailed)
 	tweakListOptions := func(options *metav1.ListOptions) {
@@ -0,0 +1,442 @@
+package pod
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	volumehelpers "k8s.io/cloud-provider/volume/helpers"
+	"k8s.io/component-helpers/storage/ephemeral"
+	storagehelpers "k8s.io/component-helpers/storage/volume"
+	pvutil "k8s.io/kubernetes/pkg/controller/volume/persistentvolume/util"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/volumebinding"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/volumezone"
+	"strings"
+)
+
+// The constraints of a volume excluding a node.
+const (
+	// ConstraintVolumeNodeAffinity is the node affinity of the bound PersistentVolume.
+	ConstraintVolumeNodeAffinity = "PersistentVolumeNodeAffinity"
+	// ConstraintVolumeZone is the zone and region labels of the bound PersistentVolume.
+	ConstraintVolumeZone = "VolumeZone"
+	// ConstraintNoMatchingVolume is the lack of an available PersistentVolume the
+	// unbound claim can bind to on the node, when the StorageClass doesn't provision.
+	ConstraintNoMatchingVolume = "NoMatchingPersistentVolume"
+	// ConstraintAllowedTopologies is the allowedTopologies of the StorageClass provisioning the claim.
+	ConstraintAllowedTopologies = "AllowedTopologies"
+	// ConstraintStorageCapacity is the lack of a CSIStorageCapacity accessible from
+	// the node with room for the claim, when the CSI driver tracks its capacity.
+	ConstraintStorageCapacity = "CSIStorageCapacity"
+	// ConstraintCSIDriver is the CSI driver of the volume missing from the CSINode
+	// of the node. The scheduler doesn't check it, it is only a hint.
+	ConstraintCSIDriver = "CSIDriver"
+)
+
+// VolumeDiagnosis explains why a PersistentVolumeClaim of the pod excludes nodes.
+type VolumeDiagnosis struct {
+	// Volume is the name of the volume in the pod.
+	Volume           string                        `json:"volume"`
+	Claim            PodReference                  `json:"claim"`
+	Phase            v1.PersistentVolumeClaimPhase `json:"phase,omitempty"`
+	StorageClass     string                        `json:"storageClass,omitempty"`
+	BindingMode      storagev1.VolumeBindingMode   `json:"bindingMode,omitempty"`
+	PersistentVolume string                        `json:"persistentVolume,omitempty"`
+	// Problem is what keeps the volume from being used on any node.
+	Problem string `json:"problem,omitempty"`
+	// ExcludedNodes are the nodes the volume excludes and why.
+	ExcludedNodes []VolumeNodeExclusion `json:"excludedNodes,omitempty"`
+}
+
+// VolumeNodeExclusion is the constraint of a volume a node fails.
+type VolumeNodeExclusion struct {
+	Node       string `json:"node"`
+	Constraint string `json:"constraint"`
+	Message    string `json:"message"`
+	// Hint tells the constraint isn't checked by the scheduler, it may explain
+	// why the node is rejected when no checked constraint does.
+	Hint bool `json:"hint,omitempty"`
+}
+
+// diagnoseVolumes walks the claims of the pod, their StorageClasses and bound
+// PersistentVolumes, and explains every node the VolumeBinding or VolumeZone
+// plugin rejects.
+func (s *ScheduleTroubleShooter) diagnoseVolumes(report *ScheduleReport) ([]VolumeDiagnosis, error) {
+	nodeInfoMap := newNodeInfoMap(s.nodeInfos)
+	nodes := make([]*v1.Node, 0)
+	for _, n := range report.Nodes {
+		if nodeInfo, ok := nodeInfoMap[n.Name]; ok && (failsPlugin(n, volumebinding.Name) || failsPlugin(n, volumezone.Name)) {
+			nodes = append(nodes, nodeInfo.Node())
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	pvs, err := s.informerFactory.Core().V1().PersistentVolumes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	diagnoses := make([]VolumeDiagnosis, 0)
+	for i := range s.pod.Spec.Volumes {
+		volume := &s.pod.Spec.Volumes[i]
+		var claimName string
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		case volume.Ephemeral != nil:
+			claimName = ephemeral.VolumeClaimName(s.pod, volume)
+		default:
+			continue
+		}
+		diagnosis, err := s.diagnoseVolume(volume.Name, claimName, nodes, pvs)
+		if err != nil {
+			return nil, err
+		}
+		if len(diagnosis.Problem) != 0 || len(diagnosis.ExcludedNodes) != 0 {
+			diagnoses = append(diagnoses, *diagnosis)
+		}
+	}
+	return diagnoses, nil
+}
+
+func (s *ScheduleTroubleShooter) diagnoseVolume(volumeName, claimName string, nodes []*v1.Node, pvs []*v1.PersistentVolume) (*VolumeDiagnosis, error) {
+	diagnosis := &VolumeDiagnosis{
+		Volume: volumeName,
+		Claim:  PodReference{Namespace: s.pod.Namespace, Name: claimName},
+	}
+	claim, err := s.informerFactory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(s.pod.Namespace).Get(claimName)
+	if errors.IsNotFound(err) {
+		diagnosis.Problem = "The PersistentVolumeClaim doesn't exist"
+		return diagnosis, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	diagnosis.Phase = claim.Status.Phase
+	diagnosis.StorageClass = storagehelpers.GetPersistentVolumeClaimClass(claim)
+	diagnosis.PersistentVolume = claim.Spec.VolumeName
+	if claim.DeletionTimestamp != nil {
+		diagnosis.Problem = "The PersistentVolumeClaim is being deleted"
+		return diagnosis, nil
+	}
+
+	var class *storagev1.StorageClass
+	if len(diagnosis.StorageClass) != 0 {
+		class, err = s.informerFactory.Storage().V1().StorageClasses().Lister().Get(diagnosis.StorageClass)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if class != nil && class.VolumeBindingMode != nil {
+			diagnosis.BindingMode = *class.VolumeBindingMode
+		}
+	}
+
+	if len(claim.Spec.VolumeName) != 0 {
+		if !metav1.HasAnnotation(claim.ObjectMeta, pvutil.AnnBindCompleted) {
+			diagnosis.Problem = fmt.Sprintf("The claim is pre-bound to PersistentVolume %s, the PersistentVolume controller must complete the binding before the pod is scheduled", claim.Spec.VolumeName)
+			return diagnosis, nil
+		}
+		pv, err := s.informerFactory.Core().V1().PersistentVolumes().Lister().Get(claim.Spec.VolumeName)
+		if errors.IsNotFound(err) {
+			diagnosis.Problem = fmt.Sprintf("The claim is bound to PersistentVolume %s which doesn't exist", claim.Spec.VolumeName)
+			return diagnosis, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if exclusion := s.excludedByBoundVolume(pv, node); exclusion != nil {
+				diagnosis.ExcludedNodes = append(diagnosis.ExcludedNodes, *exclusion)
+			}
+		}
+		return diagnosis, nil
+	}
+
+	switch {
+	case class == nil && len(diagnosis.StorageClass) == 0:
+		diagnosis.Problem = "The claim is unbound and has no StorageClass, it waits for a PersistentVolume to be bound to it"
+		return diagnosis, nil
+	case class == nil:
+		diagnosis.Problem = fmt.Sprintf("The claim is unbound and its StorageClass %s doesn't exist", diagnosis.StorageClass)
+		return diagnosis, nil
+	case diagnosis.BindingMode != storagev1.VolumeBindingWaitForFirstConsumer:
+		diagnosis.Problem = fmt.Sprintf("The claim is unbound and StorageClass %s binds immediately, the PersistentVolume controller must bind or provision it before the pod is scheduled", class.Name)
+		return diagnosis, nil
+	}
+
+	for _, node := range nodes {
+		exclusion, err := s.excludedByUnboundClaim(claim, class, pvs, node)
+		if err != nil {
+			return nil, err
+		}
+		if exclusion != nil {
+			diagnosis.ExcludedNodes = append(diagnosis.ExcludedNodes, *exclusion)
+		}
+	}
+	return diagnosis, nil
+}
+
+// excludedByBoundVolume checks the node against the node affinity and the zone
+// labels of the PersistentVolume, then hints at the CSINode of the node missing
+// the driver of the CSI volume.
+func (s *ScheduleTroubleShooter) excludedByBoundVolume(pv *v1.PersistentVolume, node *v1.Node) *VolumeNodeExclusion {
+	if pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+		if mismatches := nodeSelectorMismatches(pv.Spec.NodeAffinity.Required, node); len(mismatches) != 0 {
+			return &VolumeNodeExclusion{
+				Node:       node.Name,
+				Constraint: ConstraintVolumeNodeAffinity,
+				Message:    fmt.Sprintf("PersistentVolume %s requires %s", pv.Name, strings.Join(mismatches, "; ")),
+			}
+		}
+	}
+
+	for _, key := range []string{v1.LabelTopologyZone, v1.LabelTopologyRegion, v1.LabelFailureDomainBetaZone, v1.LabelFailureDomainBetaRegion} {
+		value, ok := pv.Labels[key]
+		if !ok {
+			continue
+		}
+		nodeValue, ok := node.Labels[key]
+		if !ok {
+			// VolumeZone only compares the labels the node has.
+			continue
+		}
+		zones, err := volumehelpers.LabelZonesToSet(value)
+		if err != nil {
+			continue
+		}
+		if !zones.Has(nodeValue) {
+			return &VolumeNodeExclusion{
+				Node:       node.Name,
+				Constraint: ConstraintVolumeZone,
+				Message:    fmt.Sprintf("PersistentVolume %s is in %s=%s, the node in %s=%s", pv.Name, key, value, key, nodeValue),
+			}
+		}
+	}
+
+	if pv.Spec.CSI != nil {
+		return s.excludedByCSIDriver(pv.Spec.CSI.Driver, node)
+	}
+	return nil
+}
+
+// excludedByUnboundClaim checks the node like the volume binder does for a claim
+// waiting for its first consumer: an available PersistentVolume matching the claim
+// on the node, or else the StorageClass provisioning a volume reachable by the node
+// with enough storage. It then hints at the CSINode of the node missing the driver.
+func (s *ScheduleTroubleShooter) excludedByUnboundClaim(claim *v1.PersistentVolumeClaim, class *storagev1.StorageClass, pvs []*v1.PersistentVolume, node *v1.Node) (*VolumeNodeExclusion, error) {
+	pv, err := pvutil.FindMatchingVolume(claim, pvs, node, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	if pv != nil {
+		return nil, nil
+	}
+
+	if len(class.Provisioner) == 0 || class.Provisioner == pvutil.NotSupportedProvisioner {
+		candidates := 0
+		for _, pv := range pvs {
+			if storagehelpers.GetPersistentVolumeClass(pv) == class.Name && pv.Spec.ClaimRef == nil {
+				candidates++
+			}
+		}
+		return &VolumeNodeExclusion{
+			Node:       node.Name,
+			Constraint: ConstraintNoMatchingVolume,
+			Message:    fmt.Sprintf("StorageClass %s doesn't provision volumes, and none of its %d available PersistentVolumes matches the size, access modes, selector and node affinity of the claim on the node", class.Name, candidates),
+		}, nil
+	}
+
+	if len(class.AllowedTopologies) > 0 {
+		allowed := false
+		terms := make([]string, 0, len(class.AllowedTopologies))
+		for _, term := range class.AllowedTopologies {
+			if topologyTermMatches(term, node) {
+				allowed = true
+				break
+			}
+			terms = append(terms, formatTopologyTerm(term))
+		}
+		if !allowed {
+			return &VolumeNodeExclusion{
+				Node:       node.Name,
+				Constraint: ConstraintAllowedTopologies,
+				Message:    fmt.Sprintf("StorageClass %s only provisions in %s, the node has %s", class.Name, strings.Join(terms, " or "), formatTopologyLabels(class.AllowedTopologies, node)),
+			}, nil
+		}
+	}
+
+	exclusion, err := s.excludedByStorageCapacity(claim, class, node)
+	if err != nil || exclusion != nil {
+		return exclusion, err
+	}
+
+	// In-tree provisioners don't register with CSINode, unless migrated.
+	if !strings.HasPrefix(class.Provisioner, "kubernetes.io/") {
+		return s.excludedByCSIDriver(class.Provisioner, node), nil
+	}
+	return nil, nil
+}
+
+// excludedByStorageCapacity checks the node has access to a CSIStorageCapacity of
+// the StorageClass with room for the claim, like the volume binder does when the
+// CSI driver opts into storage capacity tracking.
+func (s *ScheduleTroubleShooter) excludedByStorageCapacity(claim *v1.PersistentVolumeClaim, class *storagev1.StorageClass, node *v1.Node) (*VolumeNodeExclusion, error) {
+	quantity, ok := claim.Spec.Resources.Requests[v1.ResourceStorage]
+	if !ok {
+		return nil, nil
+	}
+	driver, err := s.informerFactory.Storage().V1().CSIDrivers().Lister().Get(class.Provisioner)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if driver.Spec.StorageCapacity == nil || !*driver.Spec.StorageCapacity {
+		return nil, nil
+	}
+
+	capacities, err := s.informerFactory.Storage().V1beta1().CSIStorageCapacities().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	accessible := 0
+	for _, capacity := range capacities {
+		if capacity.StorageClassName != class.Name || !nodeHasStorageAccess(node, capacity) {
+			continue
+		}
+		accessible++
+		// MaximumVolumeSize is more precise than the capacity when reported.
+		limit := capacity.Capacity
+		if capacity.MaximumVolumeSize != nil {
+			limit = capacity.MaximumVolumeSize
+		}
+		if limit != nil && limit.Cmp(quantity) >= 0 {
+			return nil, nil
+		}
+	}
+	message := fmt.Sprintf("None of the %d CSIStorageCapacities of StorageClass %s accessible from the node has room for %s", accessible, class.Name, quantity.String())
+	if accessible == 0 {
+		message = fmt.Sprintf("CSI driver %s reports no CSIStorageCapacity of StorageClass %s accessible from the node", driver.Name, class.Name)
+	}
+	return &VolumeNodeExclusion{
+		Node:       node.Name,
+		Constraint: ConstraintStorageCapacity,
+		Message:    message,
+	}, nil
+}
+
+func nodeHasStorageAccess(node *v1.Node, capacity *storagev1beta1.CSIStorageCapacity) bool {
+	if capacity.NodeTopology == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(capacity.NodeTopology)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(node.Labels))
+}
+
+// excludedByCSIDriver hints at the CSINode of the node not registering the driver,
+// the driver can't provision, attach or mount volumes on the node then, though the
+// volume binder doesn't check it.
+func (s *ScheduleTroubleShooter) excludedByCSIDriver(driver string, node *v1.Node) *VolumeNodeExclusion {
+	csiNode, err := s.informerFactory.Storage().V1().CSINodes().Lister().Get(node.Name)
+	if err != nil {
+		return &VolumeNodeExclusion{
+			Node:       node.Name,
+			Constraint: ConstraintCSIDriver,
+			Message:    fmt.Sprintf("The node has no CSINode, the CSI driver %s may not be running on it", driver),
+			Hint:       true,
+		}
+	}
+	for _, d := range csiNode.Spec.Drivers {
+		if d.Name == driver {
+			return nil
+		}
+	}
+	return &VolumeNodeExclusion{
+		Node:       node.Name,
+		Constraint: ConstraintCSIDriver,
+		Message:    fmt.Sprintf("The CSI driver %s isn't registered in the CSINode of the node", driver),
+		Hint:       true,
+	}
+}
+
+func topologyTermMatches(term v1.TopologySelectorTerm, node *v1.Node) bool {
+	for _, r := range term.MatchLabelExpressions {
+		value, ok := node.Labels[r.Key]
+		if !ok {
+			return false
+		}
+		found := false
+		for _, v := range r.Values {
+			found = found || v == value
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTopologyTerm(term v1.TopologySelectorTerm) string {
+	requirements := make([]string, 0, len(term.MatchLabelExpressions))
+	for _, r := range term.MatchLabelExpressions {
+		requirements = append(requirements, fmt.Sprintf("%s In [%s]", r.Key, strings.Join(r.Values, ",")))
+	}
+	return strings.Join(requirements, ",")
+}
+
+// formatTopologyLabels formats the labels of the node for the keys of the terms.
+func formatTopologyLabels(terms []v1.TopologySelectorTerm, node *v1.Node) string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, term := range terms {
+		for _, r := range term.MatchLabelExpressions {
+			if seen[r.Key] {
+				continue
+			}
+			seen[r.Key] = true
+			result = append(result, formatNodeLabel(node, r.Key))
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+func formatVolumeDiagnoses(diagnoses []VolumeDiagnosis) string {
+	lines := make([]string, 0)
+	for _, d := range diagnoses {
+		details := []string{fmt.Sprintf("claim %s/%s", d.Claim.Namespace, d.Claim.Name)}
+		if len(d.Phase) != 0 {
+			details = append(details, string(d.Phase))
+		}
+		if len(d.PersistentVolume) != 0 {
+			details = append(details, "volume "+d.PersistentVolume)
+		}
+		if len(d.StorageClass) != 0 {
+			class := "class " + d.StorageClass
+			if len(d.BindingMode) != 0 {
+				class += " " + string(d.BindingMode)
+			}
+			details = append(details, class)
+		}
+		lines = append(lines, fmt.Sprintf("[Volume] %s (%s):", d.Volume, strings.Join(details, ", ")))
+		if len(d.Problem) != 0 {
+			lines = append(lines, "    "+d.Problem)
+		}
+		for _, e := range d.ExcludedNodes {
+			if e.Hint {
+				lines = append(lines, fmt.Sprintf("    node %s: hint, not checked by the scheduler: %s: %s", e.Node, e.Constraint, e.Message))
+			} else {
+				lines = append(lines, fmt.Sprintf("    node %s: %s: %s", e.Node, e.Constraint, e.Message))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
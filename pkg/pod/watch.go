@@ -0,0 +1,363 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchDebounce batches the events of e.g. a node pool scaling up into one evaluation.
+const watchDebounce = 500 * time.Millisecond
+
+// clusterEvents collects the changes of the cluster since the last evaluation.
+type clusterEvents struct {
+	sync.Mutex
+	// known are the objects of the first snapshot, replayed as added when the
+	// handlers are registered.
+	known   map[types.UID]bool
+	events  []string
+	changed chan struct{}
+}
+
+func newClusterEvents() *clusterEvents {
+	return &clusterEvents{
+		known:   make(map[types.UID]bool),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (e *clusterEvents) add(event string) {
+	e.Lock()
+	e.events = append(e.events, event)
+	e.Unlock()
+	select {
+	case e.changed <- struct{}{}:
+	default:
+	}
+}
+
+// added ignores the objects the handlers are registered with.
+func (e *clusterEvents) added(uid types.UID, event string) {
+	e.Lock()
+	known := e.known[uid]
+	delete(e.known, uid)
+	e.Unlock()
+	if !known {
+		e.add(event)
+	}
+}
+
+func (e *clusterEvents) drain() []string {
+	e.Lock()
+	defer e.Unlock()
+	events := e.events
+	e.events = nil
+	return events
+}
+
+// ExecuteWatch prints the verdict, then re-evaluates the pod on the changes of the
+// nodes, pods and PVCs of the cluster, printing the nodes becoming feasible or not,
+// until the pod is bound or deleted, or the timeout elapses.
+func (s *ScheduleTroubleShooter) ExecuteWatch(timeout time.Duration) (Verdict, error) {
+	ctx := context.Background()
+	events, err := s.watchClusterEvents()
+	if err != nil {
+		return "", err
+	}
+
+	report, err := s.executeCore(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := s.printWatchReport(os.Stdout, report); err != nil {
+		return "", err
+	}
+	if report.Verdict == VerdictAlreadyScheduled {
+		return report.Verdict, nil
+	}
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-deadline:
+			fmt.Fprintf(os.Stderr, "Stopped watching after %v\n", timeout)
+			return report.Verdict, nil
+		case <-events.changed:
+		}
+		// Let the related events, e.g. of a pod and its node, arrive.
+		time.Sleep(watchDebounce)
+		causes := events.drain()
+		if len(causes) == 0 {
+			continue
+		}
+
+		verdict, err := s.refreshPod()
+		if err != nil {
+			return "", err
+		}
+		switch verdict {
+		case VerdictAlreadyScheduled:
+			fmt.Fprintf(os.Stdout, "%s Pod %s was bound to node %s\n", watchTimestamp(), s.pod.Name, s.pod.Spec.NodeName)
+			return verdict, nil
+		case VerdictDeleted:
+			fmt.Fprintf(os.Stdout, "%s Pod %s/%s was deleted or terminated\n", watchTimestamp(), s.pod.Namespace, s.pod.Name)
+			return verdict, nil
+		}
+		if err := s.takeSnapshot(); err != nil {
+			return "", err
+		}
+		current, err := s.executeCore(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		if s.outputFormat != OutputTable {
+			if !equality.Semantic.DeepEqual(report, current) {
+				if err := s.printWatchReport(os.Stdout, current); err != nil {
+					return "", err
+				}
+			}
+			report = current
+			continue
+		}
+		for _, transition := range verdictTransitions(report, current, causes) {
+			fmt.Fprintf(os.Stdout, "%s %s\n", watchTimestamp(), transition)
+		}
+		report = current
+	}
+}
+
+// printWatchReport separates the yaml documents, json objects are streamed as is.
+func (s *ScheduleTroubleShooter) printWatchReport(w io.Writer, report *ScheduleReport) error {
+	if s.outputFormat == OutputYAML {
+		if _, err := fmt.Fprintln(w, "---"); err != nil {
+			return err
+		}
+	}
+	return PrintScheduleReport(w, s.outputFormat, report)
+}
+
+// refreshPod gets the latest pod from the informer cache, it returns
+// VerdictAlreadyScheduled once the pod is bound and VerdictDeleted once it is gone.
+// Manifests not created yet are not refreshed.
+func (s *ScheduleTroubleShooter) refreshPod() (Verdict, error) {
+	if s.hypothetical {
+		return "", nil
+	}
+	pod, err := s.informerFactory.Core().V1().Pods().Lister().Pods(s.pod.Namespace).Get(s.pod.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return VerdictDeleted, nil
+		}
+		return "", err
+	}
+	s.pod = pod
+	if len(pod.Spec.NodeName) != 0 {
+		return VerdictAlreadyScheduled, nil
+	}
+	return "", nil
+}
+
+// watchClusterEvents registers the handlers of the events kube-scheduler moves
+// unschedulable pods back to the active queue on.
+func (s *ScheduleTroubleShooter) watchClusterEvents() (*clusterEvents, error) {
+	events := newClusterEvents()
+	nodeInformer := s.informerFactory.Core().V1().Nodes().Informer()
+	podInformer := s.informerFactory.Core().V1().Pods().Informer()
+	pvcInformer := s.informerFactory.Core().V1().PersistentVolumeClaims().Informer()
+	watched := s.pod.UID
+
+	nodes, err := s.informerFactory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		events.known[n.UID] = true
+	}
+	pods, err := s.informerFactory.Core().V1().Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pods {
+		events.known[p.UID] = true
+	}
+	pvcs, err := s.informerFactory.Core().V1().PersistentVolumeClaims().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs {
+		events.known[pvc.UID] = true
+	}
+
+	nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if n, ok := obj.(*v1.Node); ok {
+				events.added(n.UID, fmt.Sprintf("node %s was added", n.Name))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok1 := oldObj.(*v1.Node)
+			newNode, ok2 := newObj.(*v1.Node)
+			if ok1 && ok2 {
+				if change := nodeSchedulingChange(oldNode, newNode); len(change) != 0 {
+					events.add(fmt.Sprintf("node %s %s", newNode.Name, change))
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if n, ok := tombstoned(obj).(*v1.Node); ok {
+				events.add(fmt.Sprintf("node %s was deleted", n.Name))
+			}
+		},
+	})
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if p, ok := obj.(*v1.Pod); ok && len(p.Spec.NodeName) != 0 {
+				events.added(p.UID, fmt.Sprintf("pod %s/%s was added to node %s", p.Namespace, p.Name, p.Spec.NodeName))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok1 := oldObj.(*v1.Pod)
+			newPod, ok2 := newObj.(*v1.Pod)
+			if !ok1 || !ok2 {
+				return
+			}
+			switch {
+			case oldPod.Spec.NodeName != newPod.Spec.NodeName:
+				events.add(fmt.Sprintf("pod %s/%s was bound to node %s", newPod.Namespace, newPod.Name, newPod.Spec.NodeName))
+			case oldPod.Status.NominatedNodeName != newPod.Status.NominatedNodeName:
+				events.add(fmt.Sprintf("pod %s/%s was nominated to node %s", newPod.Namespace, newPod.Name, newPod.Status.NominatedNodeName))
+			case len(newPod.Spec.NodeName) != 0 && !labels.Equals(oldPod.Labels, newPod.Labels):
+				events.add(fmt.Sprintf("labels of pod %s/%s changed", newPod.Namespace, newPod.Name))
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			p, ok := tombstoned(obj).(*v1.Pod)
+			// The deletion of the watched pod ends the watch even though it is pending.
+			if !ok || (len(p.Spec.NodeName) == 0 && len(p.Status.NominatedNodeName) == 0 && p.UID != watched) {
+				return
+			}
+			// Terminated pods are deleted from the informer too, since it doesn't list them.
+			if p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+				events.add(fmt.Sprintf("pod %s/%s terminated", p.Namespace, p.Name))
+			} else {
+				events.add(fmt.Sprintf("pod %s/%s was deleted", p.Namespace, p.Name))
+			}
+		},
+	})
+	pvcInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pvc, ok := obj.(*v1.PersistentVolumeClaim); ok {
+				events.added(pvc.UID, fmt.Sprintf("pvc %s/%s was added", pvc.Namespace, pvc.Name))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPVC, ok1 := oldObj.(*v1.PersistentVolumeClaim)
+			newPVC, ok2 := newObj.(*v1.PersistentVolumeClaim)
+			if ok1 && ok2 && (oldPVC.Spec.VolumeName != newPVC.Spec.VolumeName || oldPVC.Status.Phase != newPVC.Status.Phase) {
+				events.add(fmt.Sprintf("pvc %s/%s became %s", newPVC.Namespace, newPVC.Name, newPVC.Status.Phase))
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if pvc, ok := tombstoned(obj).(*v1.PersistentVolumeClaim); ok {
+				events.add(fmt.Sprintf("pvc %s/%s was deleted", pvc.Namespace, pvc.Name))
+			}
+		},
+	})
+	return events, nil
+}
+
+func tombstoned(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// nodeSchedulingChange describes the changes of the node the filter plugins look at,
+// ignoring e.g. the heartbeats of the kubelet.
+func nodeSchedulingChange(oldNode, newNode *v1.Node) string {
+	switch {
+	case oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable:
+		if newNode.Spec.Unschedulable {
+			return "was cordoned"
+		}
+		return "was uncordoned"
+	case !equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints):
+		return "changed taints"
+	case !labels.Equals(oldNode.Labels, newNode.Labels):
+		return "changed labels"
+	case !equality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable):
+		return "changed allocatable resources"
+	case nodeReady(oldNode) != nodeReady(newNode):
+		if nodeReady(newNode) {
+			return "became ready"
+		}
+		return "became not ready"
+	}
+	return ""
+}
+
+func nodeReady(node *v1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// verdictTransitions tells the nodes becoming feasible or not, and the new verdict.
+func verdictTransitions(previous, current *ScheduleReport, causes []string) []string {
+	after := fmt.Sprintf("after %s", strings.Join(causes, ", "))
+	previousNodes := make(map[string]NodeResult, len(previous.Nodes))
+	for _, n := range previous.Nodes {
+		previousNodes[n.Name] = n
+	}
+
+	transitions := make([]string, 0)
+	for _, n := range current.Nodes {
+		p, existed := previousNodes[n.Name]
+		delete(previousNodes, n.Name)
+		switch {
+		case n.Fit && (!existed || !p.Fit):
+			transitions = append(transitions, fmt.Sprintf("Node %s became feasible %s", n.Name, after))
+		case !n.Fit && existed && p.Fit:
+			transitions = append(transitions, fmt.Sprintf("Node %s became infeasible (%s) %s", n.Name, strings.Join(n.Reasons, ","), after))
+		case !n.Fit && existed && !equality.Semantic.DeepEqual(n.Reasons, p.Reasons):
+			transitions = append(transitions, fmt.Sprintf("Node %s is still infeasible (%s) %s", n.Name, strings.Join(n.Reasons, ","), after))
+		}
+	}
+	gone := make([]string, 0, len(previousNodes))
+	for name := range previousNodes {
+		gone = append(gone, name)
+	}
+	sort.Strings(gone)
+	for _, name := range gone {
+		transitions = append(transitions, fmt.Sprintf("Node %s is gone %s", name, after))
+	}
+
+	if current.Verdict != previous.Verdict {
+		prefix := "[Fail]"
+		if current.Verdict == VerdictSchedulable {
+			prefix = "[Success]"
+		}
+		transitions = append(transitions, fmt.Sprintf("%s %s", prefix, current.Message))
+	}
+	return transitions
+}
+
+func watchTimestamp() string {
+	return time.Now().Format("15:04:05")
+}
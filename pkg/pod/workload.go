@@ -0,0 +1,357 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"github.com/briandowns/spinner"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"troubleshooter/pkg"
+)
+
+type workloadRef struct {
+	kind   string
+	object runtime.Object
+}
+
+// WorkloadScheduleReport aggregates the schedule of the pending pods of a workload.
+type WorkloadScheduleReport struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Workload   WorkloadReference `json:"workload"`
+	Profile    string            `json:"profile"`
+	Verdict    Verdict           `json:"verdict"`
+	Message    string            `json:"message"`
+	Templates  []TemplateResult  `json:"templates,omitempty"`
+}
+
+type WorkloadReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// TemplateResult is the outcome of the pending pods sharing a pod template.
+type TemplateResult struct {
+	// Owner is the controller of the pods, e.g. the ReplicaSet of a Deployment.
+	Owner         string        `json:"owner"`
+	Revision      string        `json:"revision,omitempty"`
+	Pending       int           `json:"pending"`
+	Unschedulable int           `json:"unschedulable"`
+	Example       PodReference  `json:"example"`
+	Reasons       []ReasonCount `json:"reasons,omitempty"`
+}
+
+type ReasonCount struct {
+	Reason   string `json:"reason"`
+	Replicas int    `json:"replicas"`
+}
+
+type podTemplateGroup struct {
+	owner    string
+	revision string
+	pods     []*v1.Pod
+}
+
+// loadWorkload gets the workload and the pod of its template, standing for the
+// pods of the workload when selecting the profile.
+func loadWorkload(ctx context.Context, cs kubernetes.Interface, ref, namespace string) (*workloadRef, *v1.Pod, error) {
+	kind, name, err := pkg.ParseWorkload(ref)
+	if err != nil {
+		return nil, nil, pkg.NewConfigError(err)
+	}
+	if len(namespace) == 0 {
+		namespace = metav1.NamespaceDefault
+	}
+
+	obj, err := pkg.GetWorkload(ctx, cs, kind, namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, pkg.NewConfigError(fmt.Errorf("%s %s in namespace %s not found\n", kind, name, namespace))
+		}
+		return nil, nil, err
+	}
+	pod, err := pkg.PodFromObject(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &workloadRef{kind: kind, object: obj}, pod, nil
+}
+
+// ExecuteWorkload prints the aggregated verdict of the pending pods of the workload.
+func (s *ScheduleTroubleShooter) ExecuteWorkload() (Verdict, error) {
+	sp := spinner.New(spinner.CharSets[21], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	sp.Start()
+
+	ctx := context.Background()
+	report, err := s.executeWorkloadCore(ctx)
+	sp.Stop()
+	if err != nil {
+		return "", err
+	}
+	err = printReport(os.Stdout, s.outputFormat, report, func() string {
+		return formatWorkloadScheduleReport(report)
+	})
+	if err != nil {
+		return "", err
+	}
+	return report.Verdict, nil
+}
+
+// executeWorkloadCore schedules the pending pods one after another, assuming each
+// schedulable one on its best node, so the replicas compete for the resources like
+// they do in kube-scheduler. Replicas identical to a failed one fail the same way.
+func (s *ScheduleTroubleShooter) executeWorkloadCore(ctx context.Context) (*WorkloadScheduleReport, error) {
+	accessor, err := meta.Accessor(s.workload.object)
+	if err != nil {
+		return nil, err
+	}
+	report := &WorkloadScheduleReport{
+		APIVersion: ScheduleReportAPIVersion,
+		Kind:       "WorkloadScheduleReport",
+		Workload: WorkloadReference{
+			Kind:      s.workload.kind,
+			Namespace: accessor.GetNamespace(),
+			Name:      accessor.GetName(),
+		},
+		Profile: s.framework.ProfileName(),
+	}
+
+	pods, err := s.pendingWorkloadPods(accessor)
+	if err != nil {
+		return nil, err
+	}
+
+	numUnschedulable := 0
+	for _, group := range groupPodsByTemplate(pods) {
+		result := TemplateResult{
+			Owner:    group.owner,
+			Revision: group.revision,
+			Pending:  len(group.pods),
+			Example:  newPodReference(group.pods[0]),
+		}
+		reasons := make(map[string]int)
+		// A pod identical to one that failed fails the same way, the others, e.g. the
+		// pods of a StatefulSet with their own claims or of a DaemonSet pinned to
+		// their own node, are evaluated on their own.
+		failed := make([]*v1.Pod, 0)
+		failedReasons := make([]string, 0)
+		for _, p := range group.pods {
+			reason := ""
+			for i, f := range failed {
+				if sameSchedulingSpec(f, p) {
+					reason = failedReasons[i]
+					break
+				}
+			}
+			if len(reason) == 0 {
+				fitErr, err := s.assumePod(ctx, p)
+				if err != nil {
+					return nil, err
+				}
+				if fitErr == nil {
+					continue
+				}
+				reason = fitErr.Error()
+				failed = append(failed, p)
+				failedReasons = append(failedReasons, reason)
+			}
+			result.Unschedulable++
+			reasons[reason]++
+		}
+		for reason, replicas := range reasons {
+			result.Reasons = append(result.Reasons, ReasonCount{Reason: reason, Replicas: replicas})
+		}
+		sort.Slice(result.Reasons, func(i, j int) bool {
+			if result.Reasons[i].Replicas != result.Reasons[j].Replicas {
+				return result.Reasons[i].Replicas > result.Reasons[j].Replicas
+			}
+			return result.Reasons[i].Reason < result.Reasons[j].Reason
+		})
+		numUnschedulable += result.Unschedulable
+		report.Templates = append(report.Templates, result)
+	}
+
+	switch {
+	case len(pods) == 0:
+		report.Verdict = VerdictAlreadyScheduled
+		report.Message = fmt.Sprintf("%s %s/%s has no pending pods", s.workload.kind, report.Workload.Namespace, report.Workload.Name)
+	case numUnschedulable == 0:
+		report.Verdict = VerdictSchedulable
+		report.Message = fmt.Sprintf("All %d pending pods of %s %s/%s can be scheduled, please wait...", len(pods), s.workload.kind, report.Workload.Namespace, report.Workload.Name)
+	default:
+		report.Verdict = VerdictUnschedulable
+		report.Message = fmt.Sprintf("%d/%d pending pods of %s %s/%s cannot be scheduled", numUnschedulable, len(pods), s.workload.kind, report.Workload.Namespace, report.Workload.Name)
+	}
+	return report, nil
+}
+
+// assumePod runs the scheduling cycle of the pod, and assumes it on the best
+// node like kube-scheduler does before binding it.
+func (s *ScheduleTroubleShooter) assumePod(ctx context.Context, pod *v1.Pod) (*framework.FitError, error) {
+	state := framework.NewCycleState()
+	result, err := findNodesThatFitPod(ctx, s.framework, state, pod, s.nodeInfos)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.feasibleNodes) == 0 {
+		return &framework.FitError{
+			Pod:         pod,
+			NumAllNodes: len(s.nodeInfos),
+			Diagnosis:   result.diagnosis,
+		}, nil
+	}
+
+	scores, err := prioritizeNodes(ctx, s.framework, state, pod, result.feasibleNodes)
+	if err != nil {
+		return nil, err
+	}
+	nodeInfo, err := s.snapshot.NodeInfos().Get(scores[0].name)
+	if err != nil {
+		return nil, err
+	}
+	assumed := pod.DeepCopy()
+	assumed.Spec.NodeName = scores[0].name
+	nodeInfo.AddPod(assumed)
+	return nil, nil
+}
+
+// pendingWorkloadPods resolves the pending pods of the workload through their owner
+// references, Deployments own their pods through ReplicaSets.
+func (s *ScheduleTroubleShooter) pendingWorkloadPods(workload metav1.Object) ([]*v1.Pod, error) {
+	owners := []metav1.Object{workload}
+	if s.workload.kind == pkg.KindDeployment {
+		rsList, err := s.informerFactory.Apps().V1().ReplicaSets().Lister().ReplicaSets(workload.GetNamespace()).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		owners = make([]metav1.Object, 0)
+		for _, rs := range rsList {
+			if metav1.IsControlledBy(rs, workload) {
+				owners = append(owners, rs)
+			}
+		}
+	}
+
+	// The pod informer of kube-scheduler has no namespace index.
+	pods, err := s.informerFactory.Core().V1().Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*v1.Pod, 0)
+	for _, p := range activePods(pods) {
+		if p.Namespace != workload.GetNamespace() || len(p.Spec.NodeName) != 0 || p.DeletionTimestamp != nil {
+			continue
+		}
+		for _, owner := range owners {
+			if metav1.IsControlledBy(p, owner) {
+				pending = append(pending, p)
+				break
+			}
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Name < pending[j].Name
+	})
+	return pending, nil
+}
+
+// sameSchedulingSpec tells whether the pods only differ by what doesn't change how
+// they are scheduled: their name, UID and hostname, and the volumes the scheduler
+// ignores, e.g. the service account token volume named randomly by admission.
+func sameSchedulingSpec(a, b *v1.Pod) bool {
+	if a.Namespace != b.Namespace || !apiequality.Semantic.DeepEqual(a.Labels, b.Labels) {
+		return false
+	}
+	return apiequality.Semantic.DeepEqual(schedulingSpec(a), schedulingSpec(b))
+}
+
+func schedulingSpec(pod *v1.Pod) *v1.PodSpec {
+	spec := pod.Spec.DeepCopy()
+	spec.Hostname = ""
+	volumes := make([]v1.Volume, 0, len(spec.Volumes))
+	for _, v := range spec.Volumes {
+		switch {
+		case v.Secret != nil, v.ConfigMap != nil, v.Projected != nil, v.DownwardAPI != nil, v.EmptyDir != nil:
+			continue
+		case v.Ephemeral != nil:
+			// The claim of a generic ephemeral volume is named after the pod.
+			v.VolumeSource = v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pod.Name + "-" + v.Name}}
+		}
+		volumes = append(volumes, v)
+	}
+	spec.Volumes = volumes
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].VolumeMounts = nil
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = nil
+	}
+	return spec
+}
+
+// groupPodsByTemplate de-duplicates the pods created from the same revision of
+// the pod template, keeping the order of the pods.
+func groupPodsByTemplate(pods []*v1.Pod) []*podTemplateGroup {
+	groups := make([]*podTemplateGroup, 0)
+	index := make(map[string]*podTemplateGroup)
+	for _, p := range pods {
+		owner := ""
+		if ref := metav1.GetControllerOfNoCopy(p); ref != nil {
+			owner = ref.Name
+		}
+		revision := p.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		if len(revision) == 0 {
+			revision = p.Labels[appsv1.ControllerRevisionHashLabelKey]
+		}
+
+		key := owner + "/" + revision
+		group, ok := index[key]
+		if !ok {
+			group = &podTemplateGroup{owner: owner, revision: revision}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.pods = append(group.pods, p)
+	}
+	return groups
+}
+
+func formatWorkloadScheduleReport(report *WorkloadScheduleReport) string {
+	var sb strings.Builder
+	if len(report.Templates) > 0 {
+		w := tabwriter.NewWriter(&sb, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "OWNER\tREVISION\tPENDING\tUNSCHEDULABLE\tREASONS")
+		for _, t := range report.Templates {
+			reasons := make([]string, 0, len(t.Reasons))
+			for _, r := range t.Reasons {
+				reasons = append(reasons, fmt.Sprintf("%d pod(s): %s", r.Replicas, r.Reason))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", t.Owner, t.Revision, t.Pending, t.Unschedulable, strings.Join(reasons, "; "))
+		}
+		w.Flush()
+	}
+
+	switch report.Verdict {
+	case VerdictSchedulable:
+		fmt.Fprintf(&sb, "[Success] %s", report.Message)
+	case VerdictUnschedulable:
+		fmt.Fprintf(&sb, "[Fail] %s", report.Message)
+	default:
+		fmt.Fprint(&sb, report.Message)
+	}
+	return sb.String()
+}
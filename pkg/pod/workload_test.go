@@ -0,0 +1,141 @@
+package pod
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestGroupPodsByTemplate(t *testing.T) {
+	rs := appsv1.SchemeGroupVersion.WithKind("ReplicaSet")
+	sts := appsv1.SchemeGroupVersion.WithKind("StatefulSet")
+	hash := appsv1.DefaultDeploymentUniqueLabelKey
+	revision := appsv1.ControllerRevisionHashLabelKey
+
+	pods := []*v1.Pod{
+		newTestPod("web-1").OwnerReference("web-abc", rs).Label(hash, "abc").Obj(),
+		newTestPod("db-0").OwnerReference("db", sts).Label(revision, "db-1").Obj(),
+		newTestPod("web-2").OwnerReference("web-abc", rs).Label(hash, "abc").Obj(),
+		newTestPod("web-3").OwnerReference("web-def", rs).Label(hash, "def").Obj(),
+		newTestPod("db-1").OwnerReference("db", sts).Label(revision, "db-2").Obj(),
+		newTestPod("db-2").OwnerReference("db", sts).Label(revision, "db-1").Obj(),
+		newTestPod("bare-1").Obj(),
+		newTestPod("bare-2").Obj(),
+	}
+	want := []struct {
+		owner    string
+		revision string
+		pods     []string
+	}{
+		{owner: "web-abc", revision: "abc", pods: []string{"web-1", "web-2"}},
+		{owner: "db", revision: "db-1", pods: []string{"db-0", "db-2"}},
+		{owner: "web-def", revision: "def", pods: []string{"web-3"}},
+		{owner: "db", revision: "db-2", pods: []string{"db-1"}},
+		{owner: "", revision: "", pods: []string{"bare-1", "bare-2"}},
+	}
+
+	groups := groupPodsByTemplate(pods)
+	if len(groups) != len(want) {
+		t.Fatalf("want %d groups, got %d", len(want), len(groups))
+	}
+	for i, g := range groups {
+		names := make([]string, 0, len(g.pods))
+		for _, p := range g.pods {
+			names = append(names, p.Name)
+		}
+		if g.owner != want[i].owner || g.revision != want[i].revision || !reflect.DeepEqual(names, want[i].pods) {
+			t.Errorf("group %d: want %s/%s %v, got %s/%s %v", i, want[i].owner, want[i].revision, want[i].pods, g.owner, g.revision, names)
+		}
+	}
+}
+
+func TestSameSchedulingSpec(t *testing.T) {
+	replica := func(name string) *v1.Pod {
+		p := newTestPod(name).OwnerReference("web-abc", appsv1.SchemeGroupVersion.WithKind("ReplicaSet")).
+			Label("app", "web").Container("web").Obj()
+		p.Spec.Hostname = name
+		p.Spec.Volumes = []v1.Volume{
+			{Name: "token-" + name, VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{}}},
+			{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{}}},
+		}
+		p.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{{Name: "token-" + name, MountPath: "/var/run/secrets"}}
+		return p
+	}
+	withClaim := func(p *v1.Pod, claimName string) *v1.Pod {
+		p.Spec.Volumes = append(p.Spec.Volumes, v1.Volume{
+			Name:         "data",
+			VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}},
+		})
+		return p
+	}
+	withEphemeral := func(p *v1.Pod) *v1.Pod {
+		p.Spec.Volumes = append(p.Spec.Volumes, v1.Volume{
+			Name:         "scratch",
+			VolumeSource: v1.VolumeSource{Ephemeral: &v1.EphemeralVolumeSource{}},
+		})
+		return p
+	}
+	withNodeAffinity := func(p *v1.Pod, nodeName string) *v1.Pod {
+		p.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+				MatchFields: []v1.NodeSelectorRequirement{{Key: "metadata.name", Operator: v1.NodeSelectorOpIn, Values: []string{nodeName}}},
+			}}},
+		}}
+		return p
+	}
+
+	tests := []struct {
+		name string
+		a, b *v1.Pod
+		want bool
+	}{
+		{
+			name: "replicas differing by name, hostname and token volume",
+			a:    replica("web-1"),
+			b:    replica("web-2"),
+			want: true,
+		},
+		{
+			name: "StatefulSet pods with their own claims",
+			a:    withClaim(replica("db-0"), "data-db-0"),
+			b:    withClaim(replica("db-1"), "data-db-1"),
+			want: false,
+		},
+		{
+			name: "pods sharing a claim",
+			a:    withClaim(replica("web-1"), "shared"),
+			b:    withClaim(replica("web-2"), "shared"),
+			want: true,
+		},
+		{
+			name: "generic ephemeral volumes claimed per pod",
+			a:    withEphemeral(replica("web-1")),
+			b:    withEphemeral(replica("web-2")),
+			want: false,
+		},
+		{
+			name: "DaemonSet pods pinned to their own node",
+			a:    withNodeAffinity(replica("ds-1"), "n1"),
+			b:    withNodeAffinity(replica("ds-2"), "n2"),
+			want: false,
+		},
+		{
+			name: "different labels",
+			a:    replica("web-1"),
+			b: func() *v1.Pod {
+				p := replica("web-2")
+				p.Labels["canary"] = "true"
+				return p
+			}(),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameSchedulingSpec(tt.a, tt.b); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
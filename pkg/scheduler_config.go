@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"k8s.io/kube-scheduler/config/v1beta3"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config/validation"
+)
+
+// LoadSchedulerConfigByPath decodes a v1beta2 or v1beta3 KubeSchedulerConfiguration
+// file the same way kube-scheduler does, so defaults and conversions match the
+// scheduler running in the cluster. An empty path yields the default configuration.
+func LoadSchedulerConfigByPath(path string) (*config.KubeSchedulerConfiguration, error) {
+	if len(path) == 0 {
+		return DefaultSchedulerConfig()
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The UniversalDecoder runs defaulting and returns the internal type by default.
+	obj, gvk, err := scheme.Codecs.UniversalDecoder().Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Scheduler config %s could not be decoded: %v\n", path, err)
+	}
+	cfg, ok := obj.(*config.KubeSchedulerConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("Scheduler config %s is a %s, not a KubeSchedulerConfiguration\n", path, gvk)
+	}
+	cfg.TypeMeta.APIVersion = gvk.GroupVersion().String()
+
+	if err := validation.ValidateKubeSchedulerConfiguration(cfg); err != nil {
+		return nil, fmt.Errorf("Scheduler config %s is invalid: %v\n", path, err)
+	}
+	return cfg, nil
+}
+
+// DefaultSchedulerConfig returns the v1beta3 defaults of kube-scheduler, with the
+// default-scheduler profile and its plugins.
+func DefaultSchedulerConfig() (*config.KubeSchedulerConfiguration, error) {
+	var versionedCfg v1beta3.KubeSchedulerConfiguration
+	scheme.Scheme.Default(&versionedCfg)
+	cfg := config.KubeSchedulerConfiguration{}
+	if err := scheme.Scheme.Convert(&versionedCfg, &cfg, nil); err != nil {
+		return nil, err
+	}
+	cfg.TypeMeta.APIVersion = v1beta3.SchemeGroupVersion.String()
+	return &cfg, nil
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1defaults "k8s.io/kubernetes/pkg/apis/core/v1"
+	"k8s.io/kubernetes/pkg/controller"
+	daemonutil "k8s.io/kubernetes/pkg/controller/daemon/util"
+	"strings"
+)
+
+const (
+	KindDeployment  = "Deployment"
+	KindReplicaSet  = "ReplicaSet"
+	KindStatefulSet = "StatefulSet"
+	KindDaemonSet   = "DaemonSet"
+	KindJob         = "Job"
+)
+
+var workloadKinds = map[string]string{
+	"deployment":   KindDeployment,
+	"deployments":  KindDeployment,
+	"deploy":       KindDeployment,
+	"replicaset":   KindReplicaSet,
+	"replicasets":  KindReplicaSet,
+	"rs":           KindReplicaSet,
+	"statefulset":  KindStatefulSet,
+	"statefulsets": KindStatefulSet,
+	"sts":          KindStatefulSet,
+	"daemonset":    KindDaemonSet,
+	"daemonsets":   KindDaemonSet,
+	"ds":           KindDaemonSet,
+	"job":          KindJob,
+	"jobs":         KindJob,
+}
+
+// ParseWorkload parses a workload reference like deployment/foo into its kind and name.
+func ParseWorkload(ref string) (string, string, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("Workload %s should be in the form kind/name, e.g. deployment/foo\n", ref)
+	}
+	kind, ok := workloadKinds[strings.ToLower(parts[0])]
+	if !ok {
+		return "", "", fmt.Errorf("Unsupported workload kind %s, supported kinds: deployment,replicaset,statefulset,daemonset,job\n", parts[0])
+	}
+	return kind, parts[1], nil
+}
+
+// GetWorkload gets the workload of the kind returned by ParseWorkload.
+func GetWorkload(ctx context.Context, cs kubernetes.Interface, kind, namespace, name string) (runtime.Object, error) {
+	switch kind {
+	case KindDeployment:
+		return cs.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	case KindReplicaSet:
+		return cs.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case KindStatefulSet:
+		return cs.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case KindDaemonSet:
+		return cs.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case KindJob:
+		return cs.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	return nil, fmt.Errorf("Unsupported workload kind %s\n", kind)
+}
+
+// LoadPodFromManifest returns the pod of the only Pod or workload of a yaml or json
+// manifest, an object without a namespace is created in the namespace.
+func LoadPodFromManifest(path, namespace string) (*v1.Pod, error) {
+	objects, err := LoadObjectsFromFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make([]*v1.Pod, 0, 1)
+	for _, obj := range objects {
+		// The namespace is set first, the UIDs of the pod and of its owner derive from it.
+		if accessor, err := meta.Accessor(obj); err == nil && len(accessor.GetNamespace()) == 0 {
+			accessor.SetNamespace(namespace)
+		}
+		pod, err := PodFromObject(obj)
+		if err != nil {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	if len(pods) != 1 {
+		return nil, fmt.Errorf("Manifest %s should have exactly one Pod, Deployment, ReplicaSet, StatefulSet, Job, DaemonSet or CronJob, found %d\n", path, len(pods))
+	}
+	return pods[0], nil
+}
+
+// PodFromObject returns the pod as created by the controller of the workload from
+// its pod template, or the pod itself, defaulted like the API server does.
+func PodFromObject(obj runtime.Object) (*v1.Pod, error) {
+	var pod *v1.Pod
+	switch o := obj.(type) {
+	case *v1.Pod:
+		pod = o.DeepCopy()
+	case *appsv1.Deployment:
+		// The Deployment controller creates the pods through a ReplicaSet named after
+		// the hash of the pod template, which also labels the pods.
+		hash := controller.ComputeHash(&o.Spec.Template, o.Status.CollisionCount)
+		rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: o.Name + "-" + hash, Namespace: o.Namespace}}
+		rs.UID = types.UID(rs.Namespace + "/" + rs.Name)
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.Template, metav1.NewControllerRef(rs, appsv1.SchemeGroupVersion.WithKind("ReplicaSet")))
+		if pod.Labels == nil {
+			pod.Labels = make(map[string]string)
+		}
+		pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = hash
+	case *appsv1.ReplicaSet:
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.Template, metav1.NewControllerRef(o, appsv1.SchemeGroupVersion.WithKind("ReplicaSet")))
+	case *appsv1.StatefulSet:
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.Template, metav1.NewControllerRef(o, appsv1.SchemeGroupVersion.WithKind("StatefulSet")))
+	case *appsv1.DaemonSet:
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.Template, metav1.NewControllerRef(o, appsv1.SchemeGroupVersion.WithKind("DaemonSet")))
+		// The DaemonSet controller tolerates the taints of unhealthy nodes.
+		daemonutil.AddOrUpdateDaemonPodTolerations(&pod.Spec)
+	case *batchv1.Job:
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.Template, metav1.NewControllerRef(o, batchv1.SchemeGroupVersion.WithKind("Job")))
+	case *batchv1.CronJob:
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.JobTemplate.Spec.Template, nil)
+	case *batchv1beta1.CronJob:
+		pod = podFromTemplate(&o.ObjectMeta, &o.Spec.JobTemplate.Spec.Template, nil)
+	default:
+		return nil, fmt.Errorf("Kind %s has no pod template\n", obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	// Pods are keyed by UID in the scheduler framework.
+	if len(pod.UID) == 0 {
+		pod.UID = types.UID(pod.Namespace + "/" + pod.Name)
+	}
+	corev1defaults.SetObjectDefaults_Pod(pod)
+	return pod, nil
+}
+
+func podFromTemplate(owner *metav1.ObjectMeta, template *v1.PodTemplateSpec, controllerRef *metav1.OwnerReference) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	pod.Name = owner.Name + "-hypothetical"
+	pod.Namespace = owner.Namespace
+	if controllerRef != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*controllerRef}
+	}
+	return pod
+}